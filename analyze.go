@@ -0,0 +1,406 @@
+package smux
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic is a problem found on the routes by Router.Analyze
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Code is invalid-route, host-overlap, duplicate-param, shadowed-route,
+	// ambiguous-segments or param-names
+	Code string `json:"code"`
+	// Routes are the names of the routes involved
+	Routes  []string `json:"routes,omitempty"`
+	Message string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if len(d.Routes) == 0 {
+		return fmt.Sprintf("%v %v: %v", d.Severity, d.Code, d.Message)
+	}
+	return fmt.Sprintf("%v %v [%v]: %v", d.Severity, d.Code, strings.Join(d.Routes, ", "), d.Message)
+}
+
+// analyzed is a route with its parsed path
+type analyzed struct {
+	route *Route
+	segs  []segment
+}
+
+// Analyze checks the routes and hostnames of the router, compiled or not. It
+// reports the routes that Compile rejects and the overlapping hostnames as
+// errors, like the repeated param names of a route and the routes never
+// reached because the segments of another route are tried first and match
+// all their paths. The sibling segments of the same priority matching some
+// common value, like {a}.{b} and {x:uint}, are warnings, as the segments
+// sharing a node with different param names, like {id} and {uid}. The
+// sibling segments of different priorities matching some common value, like
+// me and {id}, are tried by priority and only reported as info.
+func (router *Router) Analyze() []Diagnostic {
+	router.mu.Lock()
+	hosts := append([]string(nil), router.hosts...)
+	routes := append([]*Route(nil), router.routes...)
+	router.mu.Unlock()
+
+	var diags []Diagnostic
+
+	hostRouter := NewHostRouter()
+	for _, hn := range hosts {
+		if err := hostRouter.AddHostname(hn); err != nil {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Code:     "host-overlap",
+				Message:  fmt.Sprintf("hostname %v: %v", hn, err),
+			})
+		}
+	}
+
+	names := make(map[string]struct{}, len(routes))
+	var valid []analyzed
+	for _, r := range routes {
+		if r == nil {
+			continue
+		}
+		invalid := func(err error) {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Code:     "invalid-route",
+				Routes:   []string{r.name},
+				Message:  err.Error(),
+			})
+		}
+		if _, found := names[r.name]; found {
+			invalid(fmt.Errorf("route name %v already in use", r.name))
+			continue
+		}
+		names[r.name] = struct{}{}
+		if err := hostRouter.AddRoute(r); err != nil {
+			invalid(err)
+			continue
+		}
+
+		segs, _ := ParsePath(r.path)
+		diags = append(diags, duplicateParams(r)...)
+		valid = append(valid, analyzed{route: r, segs: segs})
+	}
+
+	diags = append(diags, paramNames(valid)...)
+	return append(diags, analyzeSegments(valid)...)
+}
+
+// paramNames reports the segments sharing a node of the trie, like {id} and
+// {uid}, that name their params differently. Each route reads its own names,
+// but the node is described with the names of the first route.
+func paramNames(routes []analyzed) []Diagnostic {
+	type first struct {
+		route string
+		seg   string
+	}
+	nodes := make(map[string]first)
+	reported := make(map[string]struct{})
+
+	var diags []Diagnostic
+	for _, a := range routes {
+		key := a.route.host
+		for _, s := range a.segs {
+			key += "/" + s.Comparable()
+			f, found := nodes[key]
+			if !found {
+				nodes[key] = first{a.route.name, s.String()}
+				continue
+			}
+			if f.seg == s.String() {
+				continue
+			}
+			if _, found := reported[key+" "+s.String()]; found {
+				continue
+			}
+			reported[key+" "+s.String()] = struct{}{}
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Code:     "param-names",
+				Routes:   []string{f.route, a.route.name},
+				Message:  fmt.Sprintf("segments %v and %v share a node with different param names", f.seg, s),
+			})
+		}
+	}
+	return diags
+}
+
+// duplicateParams reports the param names repeated on the path or on the
+// host of r, whose later values can't be read
+func duplicateParams(r *Route) []Diagnostic {
+	var diags []Diagnostic
+	check := func(where string, params []string) {
+		seen := make(map[string]struct{})
+		for _, p := range params {
+			if _, found := seen[p]; found {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Code:     "duplicate-param",
+					Routes:   []string{r.name},
+					Message:  fmt.Sprintf("param %v repeated on %v", p, where),
+				})
+			}
+			seen[p] = struct{}{}
+		}
+	}
+
+	var pathParams []string
+	segs, _ := ParsePath(r.path)
+	for _, s := range segs {
+		if sn, ok := s.(*searchnode); ok {
+			for n := sn; n != nil; n = n.next {
+				if n.kind != searchstatic {
+					pathParams = append(pathParams, n.paramname)
+				}
+			}
+		}
+	}
+	check("path "+r.path, pathParams)
+
+	var hostParams []string
+	for _, l := range strings.Split(r.host, ".") {
+		if l != "*" && l != "{*}" && isHostWildcard(l) {
+			hostParams = append(hostParams, strings.SplitN(l[1:len(l)-1], ":", 2)[0])
+		}
+	}
+	check("host "+r.host, hostParams)
+
+	return diags
+}
+
+// analyzeSegments compares the paths of the routes of each host, where they
+// diverge. On each node of the trie the children are tried by priority and
+// then in the order they were added.
+func analyzeSegments(routes []analyzed) []Diagnostic {
+	// order has the position of each node, by the first route reaching it
+	order := make(map[string]int)
+	for i, a := range routes {
+		key := a.route.host
+		for _, s := range a.segs {
+			key += "/" + s.Comparable()
+			if _, found := order[key]; !found {
+				order[key] = i
+			}
+		}
+	}
+
+	var diags []Diagnostic
+	reported := make(map[string]struct{})
+	for _, a := range routes {
+		for _, b := range routes {
+			if a.route == b.route || a.route.host != b.route.host {
+				continue
+			}
+
+			// i is the first segment where the paths diverge
+			i := 0
+			prefix := a.route.host
+			for i < len(a.segs) && i < len(b.segs) && a.segs[i].Comparable() == b.segs[i].Comparable() {
+				prefix += "/" + a.segs[i].Comparable()
+				i += 1
+			}
+			if i == len(a.segs) || i == len(b.segs) {
+				continue
+			}
+			sa, sb := a.segs[i], b.segs[i]
+			if sa.CatchAll() || sb.CatchAll() {
+				continue
+			}
+
+			// Only the pairs where a is tried first
+			pa, pb := sa.Priority(), sb.Priority()
+			if pa > pb || (pa == pb && order[prefix+"/"+sa.Comparable()] > order[prefix+"/"+sb.Comparable()]) {
+				continue
+			}
+
+			if shadows(a.segs[i:], b.segs[i:]) {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Code:     "shadowed-route",
+					Routes:   []string{b.route.name, a.route.name},
+					Message:  fmt.Sprintf("path %v is never reached, %v matches its requests first", b.route.path, a.route.path),
+				})
+				continue
+			}
+
+			key := prefix + "/" + sa.Comparable() + " " + sb.Comparable()
+			if _, found := reported[key]; found || !overlaps(sa, sb) {
+				continue
+			}
+			reported[key] = struct{}{}
+			// The order of segments of different priorities is known, the
+			// order of the ones of the same priority is the order they were
+			// added
+			severity, reason := SeverityInfo, "by priority"
+			if pa == pb {
+				severity, reason = SeverityWarning, "as it was added first"
+			}
+			diags = append(diags, Diagnostic{
+				Severity: severity,
+				Code:     "ambiguous-segments",
+				Routes:   []string{a.route.name, b.route.name},
+				Message:  fmt.Sprintf("segments %v and %v match some common value, %v is tried first %v", sa, sb, sa, reason),
+			})
+		}
+	}
+	return diags
+}
+
+// shadows tells if the segments a match all the paths of the segments b. A
+// catch all on a matches the rest of the path.
+func shadows(a, b []segment) bool {
+	for i := range a {
+		if a[i].CatchAll() {
+			return true
+		}
+		if i >= len(b) || !subsumes(a[i], b[i]) {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// subsumes tells if a matches all the values b matches. It's conservative:
+// false may be returned for some segments subsumed.
+func subsumes(a, b segment) bool {
+	if a.Comparable() == b.Comparable() || a.CatchAll() {
+		return true
+	}
+	if b.CatchAll() {
+		return false
+	}
+
+	sa, ok := a.(*searchnode)
+	if !ok {
+		return false
+	}
+	// A single param matches all the values of its type
+	single := sa.next == nil && sa.kind != searchstatic
+
+	witnesses, exact := segmentWitnesses(b)
+	if exact {
+		// b matches only the witnesses, like statics and enums
+		for _, w := range witnesses {
+			if !a.Match(w, nil) {
+				return false
+			}
+		}
+		return true
+	}
+
+	sb, ok := b.(*searchnode)
+	if !single || !ok || sb.next != nil || sb.kind == searchstatic {
+		return false
+	}
+	switch sa.kind {
+	case searchany:
+		return true
+	case searchsignednumber, searchid:
+		return sb.kind == searchnumber
+	case searchuuid:
+		return sb.kind == searchuuidv4
+	}
+	return false
+}
+
+// overlaps tells if some value is matched by both a and b, trying values of
+// their types
+func overlaps(a, b segment) bool {
+	wa, _ := segmentWitnesses(a)
+	for _, w := range wa {
+		if b.Match(w, nil) {
+			return true
+		}
+	}
+	wb, _ := segmentWitnesses(b)
+	for _, w := range wb {
+		if a.Match(w, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// paramWitnesses are values of the param types
+var paramWitnesses = map[searchkind][]string{
+	searchany:          {"x", "1", "x.x", "x-x"},
+	searchnumber:       {"1"},
+	searchsignednumber: {"1", "-1"},
+	searchid:           {"a1", "1"},
+	searchuuid:         {"00000000-0000-0000-0000-000000000000"},
+	searchuuidv4:       {"00000000-0000-4000-8000-000000000000"},
+}
+
+// maxWitnesses limits the combinations of values of a segment
+const maxWitnesses = 64
+
+// segmentWitnesses returns values matched by seg. exact tells if seg matches
+// only them.
+func segmentWitnesses(seg segment) ([]string, bool) {
+	switch s := seg.(type) {
+	case segmentstring:
+		return []string{string(s)}, true
+	case *searchnode:
+		witnesses := []string{""}
+		exact := true
+		for n := s; n != nil; n = n.next {
+			var values []string
+			switch {
+			case n.kind == searchstatic:
+				values = []string{n.str}
+			case n.kind == searchcustom:
+				if e, ok := n.matcher.(enummatcher); ok {
+					values = e
+				} else {
+					// Values of registered types and regular expressions are unknown
+					return nil, false
+				}
+			default:
+				values = paramWitnesses[n.kind]
+				exact = false
+			}
+
+			combined := make([]string, 0, len(witnesses)*len(values))
+			for _, w := range witnesses {
+				for _, v := range values {
+					if len(combined) == maxWitnesses {
+						exact = false
+						break
+					}
+					combined = append(combined, w+v)
+				}
+			}
+			witnesses = combined
+		}
+		return witnesses, exact
+	}
+	return nil, false
+}
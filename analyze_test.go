@@ -0,0 +1,73 @@
+package smux
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	router := NewRouter()
+	router.SetHostnames([]string{"*.local.com", "api.local.com"})
+
+	builders := []*RouteBuilder{
+		NewRoute().Name("files").Path("/f/{name}").Methods("GET"),
+		NewRoute().Name("files-ext").Path("/f/{a}.{b}").Methods("GET"),
+		NewRoute().Name("files-txt").Path("/f/{n}.txt").Methods("GET"),
+		NewRoute().Name("dup").Path("/a/{id}/b/{id}").Methods("GET"),
+		NewRoute().Name("user").Path("/users/{id:int}").Methods("GET"),
+		NewRoute().Name("user-uint").Path("/users/{id:uint}").Methods("GET"),
+		NewRoute().Name("me").Path("/users/me").Methods("GET"),
+		NewRoute().Name("env").Path("/env/{e}/x").Methods("GET"),
+		NewRoute().Name("env-enum").Path("/env/{e:enum(dev|prod)}/x").Methods("POST"),
+		NewRoute().Name("env-put").Path("/env/{name}/x").Methods("PUT"),
+		NewRoute().Name("hex").Path("/h/{x:id}").Methods("GET"),
+		NewRoute().Name("hex-uuid").Path("/h/{x:uuid}").Methods("GET"),
+		NewRoute().Name("again").Path("/again").Methods("GET"),
+		NewRoute().Name("again2").Path("/again").Methods("GET"),
+		NewRoute().Name("nohost").Host("other.com").Path("/x").Methods("GET"),
+	}
+	addRoutes(t, router, builders...)
+
+	var found []string
+	for _, d := range router.Analyze() {
+		found = append(found, d.Severity.String()+" "+d.Code+" "+strings.Join(d.Routes, ","))
+	}
+	expected := []string{
+		"error host-overlap ",
+		"error duplicate-param dup",
+		"error invalid-route again2",
+		"error invalid-route nohost",
+		"warning param-names env,env-put",
+		"info ambiguous-segments files-ext,files",
+		"warning ambiguous-segments files-ext,files-txt",
+		"info ambiguous-segments files-txt,files",
+		"error shadowed-route user-uint,user",
+		"info ambiguous-segments env-enum,env",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("Expected\n%v\nbut was\n%v", strings.Join(expected, "\n"), strings.Join(found, "\n"))
+	}
+}
+
+func TestAnalyzeClean(t *testing.T) {
+	router := newWalkRouter(t)
+	if diags := router.Analyze(); len(diags) != 0 {
+		t.Fatalf("Unexpected diagnostics %v", diags)
+	}
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	d := Diagnostic{Severity: SeverityWarning, Code: "ambiguous-segments", Routes: []string{"a", "b"}, Message: "m"}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(b) != `{"severity":"warning","code":"ambiguous-segments","routes":["a","b"],"message":"m"}` {
+		t.Fatalf("Unexpected JSON %s", b)
+	}
+	if d.String() != "warning ambiguous-segments [a, b]: m" {
+		t.Fatalf("Unexpected string %v", d)
+	}
+}
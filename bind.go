@@ -0,0 +1,225 @@
+package smux
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// routeParamTypes returns the types of the path params, like uint for
+// {id:uint}. Untyped params have empty type.
+func routeParamTypes(path string) map[string]string {
+	segs, err := ParsePath(path)
+	if err != nil {
+		return nil
+	}
+
+	types := make(map[string]string)
+	for _, seg := range segs {
+		switch s := seg.(type) {
+		case segmentcatchallstring:
+			types["*"] = ""
+		case *searchnode:
+			for sn := s; sn != nil; sn = sn.next {
+				if sn.kind != searchstatic {
+					types[sn.paramname] = sn.typename
+				}
+			}
+		}
+	}
+	return types
+}
+
+// routeParamNames returns the names of the path params in the order they are
+// found, with "*" for the catch all
+func routeParamNames(path string) []string {
+	segs, err := ParsePath(path)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, seg := range segs {
+		switch s := seg.(type) {
+		case segmentcatchallstring:
+			names = append(names, "*")
+		case *searchnode:
+			for sn := s; sn != nil; sn = sn.next {
+				if sn.kind != searchstatic {
+					names = append(names, sn.paramname)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// nameParams gives the names of r to parms, the path params found for r. The
+// routes sharing a node, like /u/{id} and /u/{uid}, find the params with the
+// names of the first route added.
+func (r *Route) nameParams(parms []PathParam) {
+	if len(parms) != len(r.paramnames) {
+		return
+	}
+	for i := range parms {
+		parms[i].Key = r.paramnames[i]
+	}
+}
+
+func (ctx Context) requirePathParam(p string) (string, error) {
+	v, found := ctx.lookupPathParam(p)
+	if !found {
+		return "", fmt.Errorf("path param %v not found", p)
+	}
+	return v, nil
+}
+
+// PathParamInt returns the path param p as an int64, like on {id:int}
+func (ctx Context) PathParamInt(p string) (int64, error) {
+	v, err := ctx.requirePathParam(p)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// PathParamUint returns the path param p as an uint64, like on {id:uint}
+func (ctx Context) PathParamUint(p string) (uint64, error) {
+	v, err := ctx.requirePathParam(p)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// PathParamUUID returns the bytes of the path param p, like on {id:uuid}
+func (ctx Context) PathParamUUID(p string) ([16]byte, error) {
+	v, err := ctx.requirePathParam(p)
+	if err != nil {
+		return [16]byte{}, err
+	}
+	return parseUUID(v)
+}
+
+func parseUUID(v string) ([16]byte, error) {
+	var u [16]byte
+	if len(v) != 36 {
+		return u, fmt.Errorf("invalid uuid %v", v)
+	}
+	for i := 0; i < len(v); i++ {
+		if !searchuuid.Accepts(v[i], i) {
+			return u, fmt.Errorf("invalid uuid %v", v)
+		}
+	}
+
+	j := 0
+	for i := 0; i < len(v); i += 2 {
+		if v[i] == '-' {
+			i -= 1
+			continue
+		}
+		u[j] = unhex(v[i])<<4 | unhex(v[i+1])
+		j += 1
+	}
+	return u, nil
+}
+
+func unhex(b byte) byte {
+	switch {
+	case b >= 'a':
+		return b - 'a' + 10
+	case b >= 'A':
+		return b - 'A' + 10
+	}
+	return b - '0'
+}
+
+var uuidType = reflect.TypeOf([16]byte{})
+
+// BindPathParams fills the fields of the struct pointed by dst tagged like
+// `smux:"name"` with the path params. Fields may be strings, integers,
+// floats or [16]byte for uuids, and must be able to hold the type declared
+// on the route.
+func (ctx Context) BindPathParams(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dst must be a pointer to struct")
+	}
+	v = v.Elem()
+
+	var types map[string]string
+	if ctx.Route != nil {
+		types = ctx.Route.paramtypes
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("smux")
+		if name == "" || name == "-" {
+			continue
+		}
+		if field.PkgPath != "" {
+			return fmt.Errorf("field %v is not exported", field.Name)
+		}
+
+		value, err := ctx.requirePathParam(name)
+		if err != nil {
+			return err
+		}
+		if err := bindValue(v.Field(i), types[name], value); err != nil {
+			return fmt.Errorf("path param %v: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func bindValue(f reflect.Value, typename, value string) error {
+	kind := f.Kind()
+	isint := kind >= reflect.Int && kind <= reflect.Int64
+	isuint := kind >= reflect.Uint && kind <= reflect.Uint64
+	isfloat := kind == reflect.Float32 || kind == reflect.Float64
+
+	switch typename {
+	case "int", "uint":
+		if kind != reflect.String && !isint && !isuint && !isfloat {
+			return fmt.Errorf("%v can't be bound to %v", typename, f.Type())
+		}
+	case "uuid", "uuidv4":
+		if kind != reflect.String && f.Type() != uuidType {
+			return fmt.Errorf("%v can't be bound to %v", typename, f.Type())
+		}
+	}
+
+	switch {
+	case kind == reflect.String:
+		f.SetString(value)
+	case isint:
+		n, err := strconv.ParseInt(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case isuint:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case isfloat:
+		n, err := strconv.ParseFloat(value, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case f.Type() == uuidType:
+		u, err := parseUUID(value)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(u))
+	default:
+		return fmt.Errorf("unsupported field type %v", f.Type())
+	}
+	return nil
+}
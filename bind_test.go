@@ -0,0 +1,149 @@
+package smux
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTypedPathParams(t *testing.T) {
+	ctx := &Context{}
+	ctx.Reset()
+	ctx.AddPathParam("n", "-42")
+	ctx.AddPathParam("u", "42")
+	ctx.AddPathParam("id", "0b0e4f3c-6a7e-4b8f-9c1d-2e3f4a5b6c7d")
+
+	if n, err := ctx.PathParamInt("n"); err != nil || n != -42 {
+		t.Fatalf("Wrong int %v %v", n, err)
+	}
+	if u, err := ctx.PathParamUint("u"); err != nil || u != 42 {
+		t.Fatalf("Wrong uint %v %v", u, err)
+	}
+	if _, err := ctx.PathParamUint("n"); err == nil {
+		t.Fatal("Must have some error")
+	}
+	if _, err := ctx.PathParamInt("missing"); err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	id, err := ctx.PathParamUUID("id")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fmt.Sprintf("%x", id) != "0b0e4f3c6a7e4b8f9c1d2e3f4a5b6c7d" {
+		t.Fatalf("Wrong uuid %x", id)
+	}
+	if _, err := ctx.PathParamUUID("u"); err == nil {
+		t.Fatal("Must have some error")
+	}
+}
+
+func TestBindPathParams(t *testing.T) {
+	router := NewRouter()
+
+	type params struct {
+		Org   string   `smux:"org"`
+		ID    uint32   `smux:"id"`
+		Run   int      `smux:"run"`
+		Trace [16]byte `smux:"trace"`
+		Other string
+	}
+
+	r1, _ := NewRoute().Path("/orgs/{org}/items/{id:uint}/runs/{run:int}/{trace:uuid}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var p params
+		if err := GetSmuxContext(r.Context()).BindPathParams(&p); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			io.WriteString(rw, err.Error())
+			return
+		}
+		fmt.Fprintf(rw, "%v %v %v %x", p.Org, p.ID, p.Run, p.Trace[:2])
+	}).Build()
+
+	r2, _ := NewRoute().Path("/wrong/{trace:uuid}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var p struct {
+			Trace int `smux:"trace"`
+		}
+		if err := GetSmuxContext(r.Context()).BindPathParams(&p); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+	}).Build()
+	router.SetRoutes([]*Route{r1, r2})
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	trace := "ab0e4f3c-6a7e-4b8f-9c1d-2e3f4a5b6c7d"
+	testCases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/orgs/acme/items/7/runs/-1/" + trace, 200, "acme 7 -1 ab0e"},
+		{"/orgs/acme/items/99999999999/runs/-1/" + trace, 400, ""},
+		{"/wrong/" + trace, 400, ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest("GET", tC.path, nil))
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v: %v", tC.code, rw.Code, rw.Body.String())
+			}
+			if tC.body != "" && rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+
+	ctx := &Context{}
+	if err := ctx.BindPathParams(params{}); err == nil {
+		t.Fatal("Must have some error")
+	}
+	if err := ctx.BindPathParams(&params{}); err == nil {
+		t.Fatal("Must have some error")
+	}
+}
+
+func TestParamNamesOfSharedNode(t *testing.T) {
+	router := NewRouter()
+
+	r1, _ := NewRoute().Path("/u/{id}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, GetSmuxContext(r.Context()).PathParam("id"))
+	}).Build()
+	r2, _ := NewRoute().Path("/u/{uid}").Methods("DELETE").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var p struct {
+			UID string `smux:"uid"`
+		}
+		if err := GetSmuxContext(r.Context()).BindPathParams(&p); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		io.WriteString(rw, p.UID)
+	}).Build()
+	router.SetRoutes([]*Route{r1, r2})
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	for _, method := range []string{"GET", "DELETE"} {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(method, "/u/42", nil))
+		if rw.Code != 200 || rw.Body.String() != "42" {
+			t.Fatalf("%v: expected 42 but was %v %v", method, rw.Code, rw.Body.String())
+		}
+	}
+
+	var walked []string
+	router.Walk(func(route *Route, host string, segments []string) error {
+		walked = append(walked, "/"+strings.Join(segments, "/"))
+		return nil
+	})
+	expected := []string{"/u/{uid}", "/u/{id}"}
+	if !reflect.DeepEqual(walked, expected) {
+		t.Fatalf("Expected %v but was %v", expected, walked)
+	}
+}
@@ -0,0 +1,223 @@
+package smux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Config declares the hostnames and routes of a router. It can be decoded
+// from JSON with ParseConfig, or from YAML with any YAML package.
+type Config struct {
+	Hostnames []string      `json:"hostnames,omitempty" yaml:"hostnames,omitempty"`
+	Routes    []RouteConfig `json:"routes" yaml:"routes"`
+}
+
+// RouteConfig declares a route. Handler and Middlewares are names on the
+// Registry given to Config.Build.
+type RouteConfig struct {
+	Name        string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Host        string            `json:"host,omitempty" yaml:"host,omitempty"`
+	Path        string            `json:"path" yaml:"path"`
+	Methods     []string          `json:"methods" yaml:"methods"`
+	Handler     string            `json:"handler" yaml:"handler"`
+	Middlewares []string          `json:"middlewares,omitempty" yaml:"middlewares,omitempty"`
+	Queries     map[string]string `json:"queries,omitempty" yaml:"queries,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Consumes    []string          `json:"consumes,omitempty" yaml:"consumes,omitempty"`
+	Produces    []string          `json:"produces,omitempty" yaml:"produces,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// ConfigError is an error on the route at Index of the config, named Name
+// when it has a name, or on the hostname at Index when Hostname is set
+type ConfigError struct {
+	Index    int
+	Name     string
+	Hostname bool
+	Err      error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Hostname {
+		return fmt.Sprintf("hostname %v (%v): %v", e.Index, e.Name, e.Err)
+	}
+	if e.Name != "" {
+		return fmt.Sprintf("route %v (%v): %v", e.Index, e.Name, e.Err)
+	}
+	return fmt.Sprintf("route %v: %v", e.Index, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Registry has the handlers and middlewares named on configs
+type Registry struct {
+	mu          sync.RWMutex
+	handlers    map[string]http.Handler
+	middlewares map[string]Middleware
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers:    make(map[string]http.Handler),
+		middlewares: make(map[string]Middleware),
+	}
+}
+
+// Handler adds the handler h named name
+func (reg *Registry) Handler(name string, h http.Handler) error {
+	if h == nil {
+		return fmt.Errorf("Handler must not be nil")
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, found := reg.handlers[name]; found {
+		return fmt.Errorf("handler %v already registered", name)
+	}
+	reg.handlers[name] = h
+	return nil
+}
+
+// Middleware adds the middleware mw named name
+func (reg *Registry) Middleware(name string, mw Middleware) error {
+	if mw == nil {
+		return fmt.Errorf("Middleware must not be nil")
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, found := reg.middlewares[name]; found {
+		return fmt.Errorf("middleware %v already registered", name)
+	}
+	reg.middlewares[name] = mw
+	return nil
+}
+
+func (reg *Registry) lookupHandler(name string) (http.Handler, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	h, found := reg.handlers[name]
+	if !found {
+		return nil, fmt.Errorf("unknown handler %v", name)
+	}
+	return h, nil
+}
+
+func (reg *Registry) lookupMiddleware(name string) (Middleware, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	mw, found := reg.middlewares[name]
+	if !found {
+		return nil, fmt.Errorf("unknown middleware %v", name)
+	}
+	return mw, nil
+}
+
+// ParseConfig decodes a JSON config. Unknown fields are errors.
+func ParseConfig(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	c := &Config{}
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return c, nil
+}
+
+// Build returns the routes of the config, with the handlers and middlewares
+// of reg. The routes are checked to compile with the hostnames of the config,
+// so the errors Compile would return are found here too. Errors on routes
+// and hostnames are *ConfigError.
+func (c Config) Build(reg *Registry) ([]*Route, error) {
+	hostRouter := NewHostRouter()
+	for i, hn := range c.Hostnames {
+		if err := hostRouter.AddHostname(hn); err != nil {
+			return nil, &ConfigError{Index: i, Name: hn, Hostname: true, Err: err}
+		}
+	}
+
+	routes := make([]*Route, 0, len(c.Routes))
+	names := make(map[string]struct{}, len(c.Routes))
+	for i, rc := range c.Routes {
+		rt, err := rc.build(reg)
+		if err == nil {
+			if _, found := names[rt.name]; found {
+				err = fmt.Errorf("route name %v already in use", rt.name)
+			} else {
+				err = hostRouter.AddRoute(rt)
+			}
+		}
+		if err != nil {
+			return nil, &ConfigError{Index: i, Name: rc.Name, Err: err}
+		}
+		names[rt.name] = struct{}{}
+		routes = append(routes, rt)
+	}
+	return routes, nil
+}
+
+// sortedPairs returns the keys and values of m, sorted by key
+func sortedPairs(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, 2*len(m))
+	for _, k := range keys {
+		pairs = append(pairs, k, m[k])
+	}
+	return pairs
+}
+
+func (rc RouteConfig) build(reg *Registry) (*Route, error) {
+	if len(rc.Methods) == 0 {
+		return nil, fmt.Errorf("no method on route")
+	}
+	h, err := reg.lookupHandler(rc.Handler)
+	if err != nil {
+		return nil, err
+	}
+	mws := make([]Middleware, len(rc.Middlewares))
+	for i, name := range rc.Middlewares {
+		if mws[i], err = reg.lookupMiddleware(name); err != nil {
+			return nil, err
+		}
+	}
+
+	rb := NewRoute().Name(rc.Name).Host(rc.Host).Path(rc.Path).Methods(rc.Methods...).Handler(h).Middleware(mws...)
+	if len(rc.Queries) > 0 {
+		rb.Queries(sortedPairs(rc.Queries)...)
+	}
+	if len(rc.Headers) > 0 {
+		rb.Headers(sortedPairs(rc.Headers)...)
+	}
+	if len(rc.Consumes) > 0 {
+		rb.Consumes(rc.Consumes...)
+	}
+	if len(rc.Produces) > 0 {
+		rb.Produces(rc.Produces...)
+	}
+	metadata := sortedPairs(rc.Metadata)
+	for i := 0; i < len(metadata); i += 2 {
+		rb.Metadata(metadata[i], metadata[i+1])
+	}
+	return rb.Build()
+}
+
+// LoadConfig sets the hostnames and routes of the router to the ones of the
+// config. The routes take effect on Compile. On error, which is the one of
+// Config.Build, the router is kept.
+func (router *Router) LoadConfig(c *Config, reg *Registry) error {
+	routes, err := c.Build(reg)
+	if err != nil {
+		return err
+	}
+	router.SetHostnames(c.Hostnames)
+	router.SetRoutes(routes)
+	return nil
+}
@@ -0,0 +1,127 @@
+package smux
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newConfigRegistry(t *testing.T) *Registry {
+	reg := NewRegistry()
+	err := reg.Handler("users", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := GetSmuxContext(r.Context())
+		io.WriteString(rw, ctx.Route.Metadata("team")+" "+ctx.PathParam("id"))
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = reg.Middleware("tag", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("X-Tag", "on")
+			next.ServeHTTP(rw, r)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reg.Handler("users", http.NotFoundHandler()) == nil {
+		t.Fatalf("Handler users must be registered once")
+	}
+	return reg
+}
+
+func TestLoadConfig(t *testing.T) {
+	doc := `{
+		"hostnames": ["api.local.com"],
+		"routes": [
+			{"name": "user", "host": "api.local.com", "path": "/users/{id:uint}", "methods": ["GET"],
+			 "handler": "users", "middlewares": ["tag"], "metadata": {"team": "accounts"}},
+			{"name": "user-v2", "host": "api.local.com", "path": "/users/{id:uint}", "methods": ["GET"],
+			 "handler": "users", "headers": {"X-Api-Version": "2"}, "metadata": {"team": "v2"}}
+		]
+	}`
+	c, err := ParseConfig(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	router := NewRouter()
+	if err := router.LoadConfig(c, newConfigRegistry(t)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "http://api.local.com/users/3", nil))
+	if rw.Body.String() != "accounts 3" || rw.Header().Get("X-Tag") != "on" {
+		t.Fatalf("Unexpected answer %v %v", rw.Body.String(), rw.Header())
+	}
+
+	rw = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://api.local.com/users/3", nil)
+	req.Header.Set("X-Api-Version", "2")
+	router.ServeHTTP(rw, req)
+	if rw.Body.String() != "v2 3" {
+		t.Fatalf("Unexpected answer %v", rw.Body.String())
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	if _, err := ParseConfig(strings.NewReader(`{"routes": [{"path": "/a", "method": ["GET"]}]}`)); err == nil {
+		t.Fatalf("Unknown fields must be errors")
+	}
+
+	reg := newConfigRegistry(t)
+	testCases := []struct {
+		route RouteConfig
+		index int
+		name  string
+	}{
+		{RouteConfig{Name: "a", Path: "/a", Methods: []string{"GET"}, Handler: "missing"}, 1, "a"},
+		{RouteConfig{Path: "/a", Methods: []string{"GET"}, Handler: "users", Middlewares: []string{"missing"}}, 1, ""},
+		{RouteConfig{Name: "b", Path: "/a/{id:foo}", Methods: []string{"GET"}, Handler: "users"}, 1, "b"},
+		{RouteConfig{Name: "c", Path: "/a", Handler: "users"}, 1, "c"},
+		{RouteConfig{Name: "d", Path: "/a", Methods: []string{"GET"}, Handler: "users", Produces: []string{"json"}}, 1, "d"},
+		{RouteConfig{Name: "ok", Path: "/a", Methods: []string{"GET"}, Handler: "users"}, 1, "ok"},
+		{RouteConfig{Name: "e", Host: "unknown.com", Path: "/a", Methods: []string{"GET"}, Handler: "users"}, 1, "e"},
+		{RouteConfig{Name: "f", Path: "/ok", Methods: []string{"GET"}, Handler: "users"}, 1, "f"},
+	}
+	for _, tC := range testCases {
+		c := Config{Routes: []RouteConfig{
+			{Name: "ok", Path: "/ok", Methods: []string{"GET"}, Handler: "users"},
+			tC.route,
+		}}
+		_, err := c.Build(reg)
+		var cerr *ConfigError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("Expected a ConfigError but was %v", err)
+		}
+		if cerr.Index != tC.index || cerr.Name != tC.name {
+			t.Fatalf("Expected error on %v (%v) but was %v", tC.index, tC.name, cerr)
+		}
+	}
+}
+
+func TestConfigHostnameErrors(t *testing.T) {
+	c := &Config{
+		Hostnames: []string{"api.local.com", "*.local.com"},
+		Routes:    []RouteConfig{{Name: "ok", Path: "/ok", Methods: []string{"GET"}, Handler: "users"}},
+	}
+	router := NewRouter()
+	err := router.LoadConfig(c, newConfigRegistry(t))
+	var cerr *ConfigError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Expected a ConfigError but was %v", err)
+	}
+	if !cerr.Hostname || cerr.Index != 1 || cerr.Name != "*.local.com" {
+		t.Fatalf("Expected error on hostname 1 but was %v", cerr)
+	}
+	if len(router.Routes()) != 0 {
+		t.Fatalf("The router must be kept on error")
+	}
+}
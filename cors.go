@@ -0,0 +1,195 @@
+package smux
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions configures the answers to cross-origin requests
+type CORSOptions struct {
+	// AllowedOrigins accepts "*", origins like "https://example.com" and
+	// origins with host wildcards like "https://*.example.com"
+	AllowedOrigins []string
+	// AllowedHeaders are the headers accepted on requests, "*" accepts any
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers readable by the browser
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authentication on requests
+	AllowCredentials bool
+	// MaxAge is how long, in seconds, the preflight answer can be cached.
+	// Zero leaves it to the browser.
+	MaxAge int
+}
+
+type corsorigin struct {
+	scheme string
+	port   string
+	host   hostentry
+}
+
+func (o corsorigin) Match(u *url.URL) bool {
+	if o.scheme != u.Scheme || o.port != u.Port() {
+		return false
+	}
+	ctx := Context{}
+	return o.host.Match(u.Hostname(), &ctx)
+}
+
+// CORS answers preflight requests with the methods of the routes of the path
+// and adds the CORS headers to the requests handled by routes.
+// Set it on Router.CORS.
+type CORS struct {
+	opts           CORSOptions
+	anyOrigin      bool
+	origins        []corsorigin
+	anyHeader      bool
+	allowedHeaders map[string]struct{}
+}
+
+func NewCORS(opts CORSOptions) (*CORS, error) {
+	c := &CORS{opts: opts, allowedHeaders: make(map[string]struct{})}
+
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			c.anyOrigin = true
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid origin %v", o)
+		}
+		if !verifyHostname(u.Hostname()) {
+			return nil, fmt.Errorf("invalid origin %v", o)
+		}
+		origin := corsorigin{scheme: u.Scheme, port: u.Port(), host: hostentry{host: u.Hostname()}}
+		origin.host.Compile()
+		c.origins = append(c.origins, origin)
+	}
+
+	var t struct{}
+	for _, h := range opts.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.allowedHeaders[http.CanonicalHeaderKey(h)] = t
+	}
+
+	if opts.MaxAge < 0 {
+		return nil, fmt.Errorf("invalid max age %v", opts.MaxAge)
+	}
+
+	return c, nil
+}
+
+func (c CORS) allowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.anyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	for _, o := range c.origins {
+		if o.Match(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORS) allowHeaders(headers []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, h := range headers {
+		if _, found := c.allowedHeaders[http.CanonicalHeaderKey(h)]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c CORS) writeOrigin(rw http.ResponseWriter, origin string) {
+	if c.anyOrigin && !c.opts.AllowCredentials {
+		rw.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		rw.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.opts.AllowCredentials {
+		rw.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// preflight answers a preflight request to a path accepting methods. The
+// CORS headers are left out when the request is not allowed.
+func (c CORS) preflight(rw http.ResponseWriter, r *http.Request, methods []string) {
+	rw.Header().Add("Vary", "Origin")
+	rw.Header().Add("Vary", "Access-Control-Request-Method")
+	rw.Header().Add("Vary", "Access-Control-Request-Headers")
+	defer rw.WriteHeader(http.StatusNoContent)
+
+	origin := r.Header.Get("Origin")
+	if !c.allowOrigin(origin) {
+		return
+	}
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	allowed := false
+	for _, m := range methods {
+		if m == method {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return
+	}
+
+	var headers []string
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, h)
+		}
+	}
+	if !c.allowHeaders(headers) {
+		return
+	}
+
+	c.writeOrigin(rw, origin)
+	rw.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ","))
+	if len(headers) > 0 {
+		rw.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ","))
+	}
+	if c.opts.MaxAge > 0 {
+		rw.Header().Set("Access-Control-Max-Age", strconv.Itoa(c.opts.MaxAge))
+	}
+}
+
+// decorate adds the CORS headers to an actual request
+func (c CORS) decorate(rw http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	rw.Header().Add("Vary", "Origin")
+	if !c.allowOrigin(origin) {
+		return
+	}
+	c.writeOrigin(rw, origin)
+	if len(c.opts.ExposedHeaders) > 0 {
+		rw.Header().Set("Access-Control-Expose-Headers", strings.Join(c.opts.ExposedHeaders, ","))
+	}
+}
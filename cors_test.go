@@ -0,0 +1,131 @@
+package smux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	router := NewRouter()
+
+	cors, err := NewCORS(CORSOptions{
+		AllowedOrigins:   []string{"https://*.example.com", "http://localhost:8080"},
+		AllowedHeaders:   []string{"content-type", "X-Request-Id"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	router.CORS = cors
+
+	r1, _ := NewRoute().Path("/users").Methods("GET", "POST").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}).Build()
+	r2, _ := NewRoute().Path("/users/{id}").Methods("GET", "DELETE").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}).Build()
+	router.SetRoutes([]*Route{r1, r2})
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		desc    string
+		path    string
+		origin  string
+		method  string
+		headers string
+		allowed string
+	}{
+		{"allowed", "/users", "https://app.example.com", "POST", "Content-Type", "GET,POST"},
+		{"allowed per path", "/users/12", "https://app.example.com", "DELETE", "", "DELETE,GET"},
+		{"allowed port", "/users", "http://localhost:8080", "GET", "x-request-id", "GET,POST"},
+		{"method of other path", "/users", "https://app.example.com", "DELETE", "", ""},
+		{"origin not allowed", "/users", "https://example.org", "GET", "", ""},
+		{"scheme not allowed", "/users", "http://app.example.com", "GET", "", ""},
+		{"port not allowed", "/users", "http://localhost:9090", "GET", "", ""},
+		{"header not allowed", "/users", "https://app.example.com", "GET", "Authorization", ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", tC.path, nil)
+			req.Header.Set("Origin", tC.origin)
+			req.Header.Set("Access-Control-Request-Method", tC.method)
+			if tC.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tC.headers)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusNoContent {
+				t.Fatalf("Must be no content but was %v", rw.Code)
+			}
+			if methods := rw.Header().Get("Access-Control-Allow-Methods"); methods != tC.allowed {
+				t.Fatalf("Expected methods %v but were %v", tC.allowed, methods)
+			}
+			if tC.allowed == "" {
+				if rw.Header().Get("Access-Control-Allow-Origin") != "" {
+					t.Fatal("Must not allow origin")
+				}
+				return
+			}
+			if rw.Header().Get("Access-Control-Allow-Origin") != tC.origin {
+				t.Fatalf("Wrong origin %v", rw.Header().Get("Access-Control-Allow-Origin"))
+			}
+			if rw.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Fatal("Must allow credentials")
+			}
+			if rw.Header().Get("Access-Control-Max-Age") != "600" {
+				t.Fatal("Wrong max age")
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	if rw.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatal("Must allow origin on actual request")
+	}
+	if rw.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Fatal("Must expose headers on actual request")
+	}
+
+	req = httptest.NewRequest("OPTIONS", "/unknown", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("Must be not found but was %v", rw.Code)
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	cors, err := NewCORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("OPTIONS", "/users", nil)
+	req.Header.Set("Origin", "https://example.org")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "Authorization")
+	rw := httptest.NewRecorder()
+	cors.preflight(rw, req, []string{"GET"})
+
+	if rw.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatal("Must allow any origin")
+	}
+	if rw.Header().Get("Access-Control-Allow-Headers") != "Authorization" {
+		t.Fatal("Must allow any header")
+	}
+}
+
+func TestCORSInvalidOrigin(t *testing.T) {
+	for _, o := range []string{"example.com", "https://www.example*.com", "https://example.com/path"} {
+		if _, err := NewCORS(CORSOptions{AllowedOrigins: []string{o}}); err == nil {
+			t.Fatalf("Origin %v must have some error", o)
+		}
+	}
+}
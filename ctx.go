@@ -3,6 +3,7 @@ package smux
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -27,9 +28,21 @@ type Context struct {
 	Route      *Route
 	pathParams []PathParam
 	HostParams []string
-	RoutePath  string
-	handler    http.Handler
-	parentCtx  context.Context
+	hostParams []PathParam
+	// query of the request, parsed on the first use
+	query       url.Values
+	queryParams []PathParam
+	RoutePath   string
+	// rest of the path matched by the catch all, before the unescaping
+	rest       string
+	allowed    []string
+	negotiated string
+	// fold matches the static parts of the paths ignoring case
+	fold      bool
+	handler   http.Handler
+	parentCtx context.Context
+	// bound is set when the context is handed to a handler
+	bound bool
 }
 
 func GetSmuxContext(ctx context.Context) *Context {
@@ -46,20 +59,85 @@ func GetSmuxContext(ctx context.Context) *Context {
 func (ctx *Context) Reset() {
 	ctx.pathParams = nil
 	ctx.HostParams = nil
+	ctx.hostParams = nil
+	ctx.query = nil
+	ctx.queryParams = nil
 	ctx.RoutePath = ""
+	ctx.rest = ""
 	ctx.Route = nil
+	ctx.allowed = nil
+	ctx.negotiated = ""
+	ctx.fold = false
 	ctx.handler = nil
+	ctx.parentCtx = nil
+}
+
+// bind returns a copy of r whose context is ctx, chained to the context of r.
+// A context already bound is on the context of r, which is kept.
+func (ctx *Context) bind(r *http.Request) *http.Request {
+	if ctx.bound {
+		return r
+	}
+	ctx.parentCtx = r.Context()
+	ctx.bound = true
+	return r.WithContext((*directContext)(ctx))
+}
+
+// AllowedMethods returns the methods accepted by the path when the method of
+// the request is not
+func (ctx Context) AllowedMethods() []string {
+	return ctx.allowed
+}
+
+// NegotiatedType returns the media type of the Produces of the route chosen
+// for the Accept header of the request, empty when the route has no Produces
+func (ctx Context) NegotiatedType() string {
+	return ctx.negotiated
 }
 
 func (ctx Context) PathParam(p string) string {
+	v, _ := ctx.lookupPathParam(p)
+	return v
+}
+
+func (ctx Context) lookupPathParam(p string) (string, bool) {
 	for i := range ctx.pathParams {
 		if ctx.pathParams[i].Key == p {
-			return string(ctx.pathParams[i].Value)
+			return string(ctx.pathParams[i].Value), true
+		}
+	}
+	return "", false
+}
+
+// HostParam returns the value of the host label named p, like tenant on
+// {tenant}.example.com. The {*} catch all is named "*".
+func (ctx Context) HostParam(p string) string {
+	for i := range ctx.hostParams {
+		if ctx.hostParams[i].Key == p {
+			return ctx.hostParams[i].Value
+		}
+	}
+	return ""
+}
+
+// QueryParam returns the value of the param p of the queries of the route,
+// like fmt on Queries("format", "{fmt:enum(json|xml)}")
+func (ctx Context) QueryParam(p string) string {
+	for i := range ctx.queryParams {
+		if ctx.queryParams[i].Key == p {
+			return ctx.queryParams[i].Value
 		}
 	}
 	return ""
 }
 
+func (ctx *Context) queryValues(r *http.Request) url.Values {
+	if ctx.query == nil {
+		ctx.query = r.URL.Query()
+	}
+	return ctx.query
+}
+
 func (ctx *Context) AddPathParam(key string, parm string) {
 	ctx.pathParams = append(ctx.pathParams, PathParam{key, parm})
 }
@@ -93,21 +171,28 @@ type directContext Context
 
 var _ context.Context = (*directContext)(nil)
 
+func (d *directContext) parent() context.Context {
+	if d.parentCtx == nil {
+		return context.Background()
+	}
+	return d.parentCtx
+}
+
 func (d *directContext) Deadline() (deadline time.Time, ok bool) {
-	return d.parentCtx.Deadline()
+	return d.parent().Deadline()
 }
 
 func (d *directContext) Done() <-chan struct{} {
-	return d.parentCtx.Done()
+	return d.parent().Done()
 }
 
 func (d *directContext) Err() error {
-	return d.parentCtx.Err()
+	return d.parent().Err()
 }
 
 func (d *directContext) Value(key interface{}) interface{} {
 	if key == ParamContext {
 		return (*Context)(d)
 	}
-	return d.parentCtx.Value(key)
+	return d.parent().Value(key)
 }
@@ -0,0 +1,78 @@
+package smux
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testCtxKey struct{}
+
+func TestContextPropagation(t *testing.T) {
+	router := NewRouter()
+
+	r1, _ := NewRoute().Path("/users/{id}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Must have the deadline of the parent")
+		}
+		if ctx.Done() == nil || ctx.Err() != nil {
+			t.Error("Must have the Done of the parent")
+		}
+		v, _ := ctx.Value(testCtxKey{}).(string)
+		io.WriteString(rw, v+" "+GetSmuxContext(ctx).PathParam("id"))
+	}).Build()
+	router.AddRoute(r1)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), testCtxKey{}, "traced"), time.Minute)
+	defer cancel()
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "/users/12", nil).WithContext(parent))
+	if rw.Body.String() != "traced 12" {
+		t.Fatalf("Unexpected response %v", rw.Body.String())
+	}
+}
+
+func TestContextRetainedByHandler(t *testing.T) {
+	router := NewRouter()
+
+	var retained []context.Context
+	r1, _ := NewRoute().Path("/users/{id}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		retained = append(retained, r.Context())
+	}).Build()
+	router.AddRoute(r1)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/"+id, nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown", nil))
+	}
+
+	for i, id := range []string{"1", "2", "3"} {
+		if v := GetSmuxContext(retained[i]).PathParam("id"); v != id {
+			t.Fatalf("Retained context must keep id %v but has %v", id, v)
+		}
+	}
+}
+
+func TestDirectContextWithoutParent(t *testing.T) {
+	ctx := &Context{}
+	ctx.Reset()
+	d := (*directContext)(ctx)
+
+	if d.Done() != nil || d.Err() != nil || d.Value(testCtxKey{}) != nil {
+		t.Fatal("Must behave as an empty context")
+	}
+	if d.Value(ParamContext) != ctx {
+		t.Fatal("Must return the smux context")
+	}
+}
@@ -0,0 +1,139 @@
+package smux
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Group declares routes sharing a path prefix, a host, default methods and
+// middlewares. Its settings are folded into each RouteBuilder before Build.
+type Group struct {
+	router      *Router
+	prefix      string
+	host        string
+	methods     []string
+	middlewares []Middleware
+	err         error
+}
+
+// Group calls fn with a group whose routes are prefixed with prefix and
+// added to the router. The first error found while declaring the group is
+// returned.
+func (router *Router) Group(prefix string, fn func(g *Group)) error {
+	g := &Group{router: router}
+	g.prefix = g.join(prefix)
+	fn(g)
+	return g.err
+}
+
+func (g *Group) join(prefix string) string {
+	if g.err != nil || prefix == "" {
+		return g.prefix
+	}
+	if strings.Contains(prefix, "{*}") || !verifyPath(prefix) {
+		g.err = fmt.Errorf("invalid group prefix %v", prefix)
+		return g.prefix
+	}
+	return joinPath(g.prefix, prefix)
+}
+
+func joinPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + path
+}
+
+// Group declares a nested group, which inherits the settings of g
+func (g *Group) Group(prefix string, fn func(g *Group)) {
+	if g.err != nil {
+		return
+	}
+	ng := &Group{
+		router:      g.router,
+		prefix:      g.prefix,
+		host:        g.host,
+		methods:     g.methods,
+		middlewares: append([]Middleware(nil), g.middlewares...),
+	}
+	ng.prefix = ng.join(prefix)
+	if ng.err == nil {
+		fn(ng)
+	}
+	g.err = ng.err
+}
+
+// Host sets the host used by routes that don't declare one
+func (g *Group) Host(h string) *Group {
+	if g.err != nil {
+		return g
+	}
+	if !verifyHost(h) {
+		g.err = fmt.Errorf("invalid host")
+		return g
+	}
+	g.host = h
+	return g
+}
+
+// Methods sets the methods used by routes that don't declare any
+func (g *Group) Methods(ms ...string) *Group {
+	if g.err != nil {
+		return g
+	}
+	g.methods = ms
+	return g
+}
+
+// Use appends middlewares applied to every route of the group, before the
+// route's own middlewares
+func (g *Group) Use(mws ...Middleware) *Group {
+	if g.err != nil {
+		return g
+	}
+	for _, mw := range mws {
+		if mw == nil {
+			g.err = fmt.Errorf("Middleware must not be nil")
+			return g
+		}
+	}
+	g.middlewares = append(g.middlewares, mws...)
+	return g
+}
+
+// Route folds the group settings into route, builds it and adds it to the
+// router.
+func (g *Group) Route(route *RouteBuilder) *Group {
+	if g.err != nil {
+		return g
+	}
+	g.fold(route)
+	rt, err := route.Build()
+	if err != nil {
+		g.err = err
+		return g
+	}
+	g.router.AddRoute(rt)
+	return g
+}
+
+func (g Group) fold(route *RouteBuilder) {
+	if route.err != nil {
+		return
+	}
+	route.path = joinPath(g.prefix, route.path)
+	if route.host == "" {
+		route.host = g.host
+	}
+	if len(route.methods) == 0 && len(g.methods) > 0 {
+		route.Methods(g.methods...)
+	}
+	mws := make([]Middleware, 0, len(g.middlewares)+len(route.middlewares))
+	mws = append(mws, g.middlewares...)
+	route.middlewares = append(mws, route.middlewares...)
+}
+
+// GetError returns the first error found while declaring the group
+func (g Group) GetError() error {
+	return g.err
+}
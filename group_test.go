@@ -0,0 +1,96 @@
+package smux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroupRoutes(t *testing.T) {
+	router := NewRouter()
+	router.SetHostnames([]string{"api.example.com"})
+
+	mark := func(s string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				io.WriteString(rw, s)
+				next.ServeHTTP(rw, r)
+			})
+		}
+	}
+
+	write := func(s string) func(http.ResponseWriter, *http.Request) {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, s)
+		}
+	}
+
+	err := router.Group("/api/v2", func(g *Group) {
+		g.Host("api.example.com").Methods("GET").Use(mark("g-"))
+
+		g.Route(NewRoute().Path("/users").HandlerFunc(write("users")))
+		g.Route(NewRoute().Path("/users").Methods("POST").HandlerFunc(write("create")))
+
+		g.Group("/admin", func(g *Group) {
+			g.Use(mark("admin-"))
+			g.Route(NewRoute().Path("/stats").Middleware(mark("r-")).HandlerFunc(write("stats")))
+		})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		method string
+		target string
+		code   int
+		body   string
+	}{
+		{"GET", "http://api.example.com/api/v2/users", 200, "g-users"},
+		{"POST", "http://api.example.com/api/v2/users", 200, "g-create"},
+		{"GET", "http://api.example.com/api/v2/admin/stats", 200, "g-admin-r-stats"},
+		{"GET", "http://www.example.com/api/v2/users", 404, ""},
+		{"GET", "http://api.example.com/users", 404, ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.method+" "+tC.target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(tC.method, tC.target, nil))
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if tC.code == 200 && rw.Body.String() != tC.body {
+				t.Fatalf("Expected body %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	router := NewRouter()
+
+	err := router.Group("/api/{*}", func(g *Group) {})
+	if err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	err = router.Group("/api", func(g *Group) {
+		g.Host("www.example*.com")
+	})
+	if err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	err = router.Group("/api", func(g *Group) {
+		g.Route(NewRoute().Path("/users").Methods("GET"))
+	})
+	if err == nil {
+		t.Fatal("Must have some error")
+	}
+	t.Logf("Error: %v", err)
+}
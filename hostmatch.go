@@ -7,19 +7,31 @@ import (
 )
 
 var hostSegmentRegex = regexp.MustCompile(`^([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])$`)
+var hostParamRegex = regexp.MustCompile(`^` + bracketPattern + `$`)
 
+// isHostWildcard tells if the label of a host pattern matches many labels
+func isHostWildcard(s string) bool {
+	return s == "*" || s == "{*}" || hostParamRegex.MatchString(s)
+}
+
+// Accepts hosts with wildcards on the left side only. "*", {name} and
+// {name:type} match one label, {*} matches one or more and must be the first.
 func verifyHostname(host string) bool {
 	allowStar := true
-	for _, s := range strings.Split(host, ".") {
-		if s == "*" && !allowStar {
-			return false
-		}
-		if s != "*" {
-			if !hostSegmentRegex.MatchString(s) {
+	for i, s := range strings.Split(host, ".") {
+		if isHostWildcard(s) {
+			if !allowStar || (s == "{*}" && i > 0) {
 				return false
 			}
-			allowStar = false
+			if _, err := createSegment(s); s != "*" && err != nil {
+				return false
+			}
+			continue
+		}
+		if !hostSegmentRegex.MatchString(s) {
+			return false
 		}
+		allowStar = false
 	}
 
 	return true
@@ -30,29 +42,64 @@ func verifyHost(host string) bool {
 }
 
 type hostentry struct {
-	host         string
-	segs         []string
+	host string
+	segs []string
+	// labels has the segment matching each wildcard label, nil on the others
+	labels       []segment
+	catchall     bool
 	numwildcards int
 	t            PathRouter
 }
 
+// labelsIntersect tells if some label is matched by both a and b
+func labelsIntersect(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if isHostWildcard(a) && isHostWildcard(b) {
+		return true
+	}
+	if isHostWildcard(b) {
+		a, b = b, a
+	}
+	if !isHostWildcard(a) {
+		return false
+	}
+	if a == "*" {
+		return true
+	}
+	seg, err := createSegment(a)
+	return err == nil && seg.Match(b, nil)
+}
+
 func (h1 hostentry) Intersects(h2 hostentry) bool {
 	h1splt := strings.Split(h1.host, ".")
 	h2splt := strings.Split(h2.host, ".")
 
-	if len(h1splt) != len(h2splt) {
-		return false
+	c1 := h1splt[0] == "{*}"
+	if c1 {
+		h1splt = h1splt[1:]
+	}
+	c2 := h2splt[0] == "{*}"
+	if c2 {
+		h2splt = h2splt[1:]
 	}
 
-	for i := range h1splt {
-		hh1 := h1splt[i]
-		hh2 := h2splt[i]
+	// {*} takes one label at least
+	switch {
+	case !c1 && !c2 && len(h1splt) != len(h2splt):
+		return false
+	case c1 && !c2 && len(h2splt) <= len(h1splt):
+		return false
+	case c2 && !c1 && len(h1splt) <= len(h2splt):
+		return false
+	}
 
-		if hh1 == hh2 || hh1 == "*" || hh2 == "*" {
-			continue
+	// Compare the labels from the right
+	for i, j := len(h1splt)-1, len(h2splt)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if !labelsIntersect(h1splt[i], h2splt[j]) {
+			return false
 		}
-
-		return false
 	}
 
 	return true
@@ -61,36 +108,74 @@ func (h1 hostentry) Intersects(h2 hostentry) bool {
 // Compile Makes easier to find the correct host
 func (h *hostentry) Compile() {
 	h.segs = strings.Split(h.host, ".")
+	h.labels = make([]segment, len(h.segs))
+	h.catchall = false
 	h.numwildcards = 0
-	for _, s := range h.segs {
-		if s == "*" {
-			h.numwildcards += 1
+	for i, s := range h.segs {
+		if !isHostWildcard(s) {
+			continue
+		}
+		h.numwildcards += 1
+		switch s {
+		case "{*}":
+			h.catchall = true
+		case "*":
+			h.labels[i] = &searchnode{kind: searchany, matcher: searchany}
+		default:
+			h.labels[i], _ = createSegment(s)
 		}
 	}
 }
 
 func (h hostentry) Match(hostname string, ctx *Context) bool {
-	si := 0
-	ctx.HostParams = make([]string, h.numwildcards)
+	ctx.HostParams = make([]string, 0, h.numwildcards)
+	ctx.hostParams = make([]PathParam, 0, h.numwildcards)
+
+	segs := h.segs
+	labels := h.labels
+	if h.catchall {
+		// The catch all takes the labels left by the others
+		j := len(hostname)
+		for range segs[1:] {
+			j = strings.LastIndexByte(hostname[:j], '.')
+			if j < 0 {
+				return false
+			}
+		}
+		if j == 0 {
+			return false
+		}
+		ctx.HostParams = append(ctx.HostParams, hostname[:j])
+		ctx.hostParams = append(ctx.hostParams, PathParam{Key: "*", Value: hostname[:j]})
+		if j == len(hostname) {
+			return true
+		}
+		hostname = hostname[j+1:]
+		segs = segs[1:]
+		labels = labels[1:]
+	}
+
 	j := -1
-	for i := range h.segs {
+	for i := range segs {
 		j = strings.Index(hostname, ".")
 
 		if j == -1 {
 			j = len(hostname)
 		}
 
-		if h.segs[i] != "*" && h.segs[i] != hostname[:j] {
-			return false
-		}
-
-		if h.segs[i] == "*" {
-			ctx.HostParams[si] = hostname[:j]
-			si += 1
+		if labels[i] == nil {
+			if segs[i] != hostname[:j] {
+				return false
+			}
+		} else {
+			if !labels[i].Match(hostname[:j], &ctx.hostParams) {
+				return false
+			}
+			ctx.HostParams = append(ctx.HostParams, hostname[:j])
 		}
 
 		if len(hostname) == j {
-			if i < len(h.segs)-1 {
+			if i < len(segs)-1 {
 				return false
 			} else {
 				return true
@@ -132,6 +217,8 @@ func (h *HostRouter) AddHostname(hostname string) error {
 // Accepts only
 // {something} string not pointed
 //   Example: {a}.local matches foo.local and bar.local, but not foo.bar.local
+// {something:type} string not pointed of the type, like on paths
+//   Example: {id:uint}.local matches 12.local, but not foo.local
 // {*} catch all on left
 //   Example: {*}.local matches foo.local, bar.local and foo.bar.local
 func (h *HostRouter) AddRoute(rt *Route) error {
@@ -146,28 +233,77 @@ func (h *HostRouter) AddRoute(rt *Route) error {
 		return fmt.Errorf("no method on route")
 	}
 
+	t, err := h.pathRouter(rt.host)
+	if err != nil {
+		return err
+	}
+	return (*t).Add(rt)
+}
+
+func (h *HostRouter) pathRouter(host string) (*PathRouter, error) {
 	// Empty host is considered 'All hosts'
-	if rt.host == "" {
-		return h.allhost.Add(rt)
+	if host == "" {
+		return &h.allhost, nil
 	}
 
 	for i := range h.hosts {
-		if h.hosts[i].host == rt.host {
-			return h.hosts[i].t.Add(rt)
+		if h.hosts[i].host == host {
+			return &h.hosts[i].t, nil
 		}
 	}
 
-	return fmt.Errorf("host not found")
+	return nil, fmt.Errorf("host not found")
 }
 
+// update returns a copy of h where fn was applied to a fork of the path router
+// of rt's host. h is not modified, so it can keep serving requests.
+func (h *HostRouter) update(rt *Route, fn func(PathRouter, *Route) error) (*HostRouter, error) {
+	nh := &HostRouter{hosts: append([]hostentry(nil), h.hosts...), allhost: h.allhost}
+	t, err := nh.pathRouter(rt.host)
+	if err != nil {
+		return nil, err
+	}
+	*t = (*t).Fork(rt)
+	if err := fn(*t, rt); err != nil {
+		return nil, err
+	}
+	return nh, nil
+}
+
+// with returns a copy of h with rt added
+func (h *HostRouter) with(rt *Route) (*HostRouter, error) {
+	if !verifyPath(rt.path) {
+		return nil, fmt.Errorf("invalid path")
+	}
+
+	if len(rt.methods) == 0 {
+		return nil, fmt.Errorf("no method on route")
+	}
+
+	return h.update(rt, PathRouter.Add)
+}
+
+// without returns a copy of h with rt removed
+func (h *HostRouter) without(rt *Route) (*HostRouter, error) {
+	return h.update(rt, PathRouter.Remove)
+}
+
+// Get finds the routes of path on the first host matching hostname. The host
+// params already on ctx, like the ones of a parent router, are kept.
 func (h HostRouter) Get(hostname, path string, ctx *Context) MatchResult {
+	labels, params := ctx.HostParams, ctx.hostParams
 	for i := range h.hosts {
 		if h.hosts[i].Match(hostname, ctx) {
+			if len(params) > 0 {
+				ctx.HostParams = append(labels[:len(labels):len(labels)], ctx.HostParams...)
+				ctx.hostParams = append(params[:len(params):len(params)], ctx.hostParams...)
+			}
 			r := h.hosts[i].t.Get(path, ctx)
 			if r != nil {
 				return r
 			}
 		}
+		ctx.HostParams, ctx.hostParams = labels, params
 	}
 
 	return h.allhost.Get(path, ctx)
@@ -453,3 +453,79 @@ func TestAddNilToHR(t *testing.T) {
 	hr := NewHostRouter()
 	hr.AddRoute(nil)
 }
+
+func TestVerifyHostnameParams(t *testing.T) {
+	for _, h := range []string{"{tenant}.example.com", "{id:uint}.example.com", "{*}.example.com", "{*}.{env}.example.com", "{a}.*.example.com"} {
+		if !verifyHostname(h) {
+			t.Fatalf("hostname %v should be accepted", h)
+		}
+	}
+	for _, h := range []string{"www.{tenant}.com", "{id:foo}.example.com", "*.{*}.example.com", "{tenant.example.com", "{a-b}.example.com"} {
+		if verifyHostname(h) {
+			t.Fatalf("hostname %v should not be accepted", h)
+		}
+	}
+}
+
+func TestMatchNamedParams(t *testing.T) {
+	testCases := []struct {
+		host     string
+		hostname string
+		match    bool
+		params   map[string]string
+	}{
+		{"{tenant}.example.com", "acme.example.com", true, map[string]string{"tenant": "acme"}},
+		{"{tenant}.example.com", "a.acme.example.com", false, nil},
+		{"{id:uint}.example.com", "42.example.com", true, map[string]string{"id": "42"}},
+		{"{id:uint}.example.com", "acme.example.com", false, nil},
+		{"{*}.example.com", "acme.example.com", true, map[string]string{"*": "acme"}},
+		{"{*}.example.com", "a.b.example.com", true, map[string]string{"*": "a.b"}},
+		{"{*}.example.com", "example.com", false, nil},
+		{"{*}.{env}.example.com", "a.b.dev.example.com", true, map[string]string{"*": "a.b", "env": "dev"}},
+		{"{*}.{env}.example.com", "dev.example.com", false, nil},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.host+" "+tC.hostname, func(t *testing.T) {
+			ctx := &Context{}
+			h := hostentry{host: tC.host}
+			h.Compile()
+
+			if h.Match(tC.hostname, ctx) != tC.match {
+				t.Fatalf("Match must be %v", tC.match)
+			}
+			for k, v := range tC.params {
+				if ctx.HostParam(k) != v {
+					t.Fatalf("Host param %v must be %v but was %v", k, v, ctx.HostParam(k))
+				}
+			}
+			if tC.match && len(ctx.HostParams) != len(tC.params) {
+				t.Fatalf("Wrong host params %v", ctx.HostParams)
+			}
+		})
+	}
+}
+
+func TestEntriesIntersectionParams(t *testing.T) {
+	testCases := []struct {
+		h1         string
+		h2         string
+		intersects bool
+	}{
+		{"{tenant}.example.com", "*.example.com", true},
+		{"{id:uint}.example.com", "www.example.com", false},
+		{"{id:uint}.example.com", "42.example.com", true},
+		{"{*}.example.com", "a.b.example.com", true},
+		{"{*}.example.com", "example.com", false},
+		{"{*}.example.com", "{*}.com", true},
+		{"{*}.example.com", "a.example.org", false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.h1+" "+tC.h2, func(t *testing.T) {
+			he1 := hostentry{host: tC.h1}
+			he2 := hostentry{host: tC.h2}
+			if he1.Intersects(he2) != tC.intersects || he2.Intersects(he1) != tC.intersects {
+				t.Fatalf("Intersects must be %v", tC.intersects)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package smux
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// MatcherFunc tells if a route handles the request. Routes with the same path
+// and method are told apart by their matchers.
+type MatcherFunc func(*http.Request, *Context) bool
+
+// hasMatchers tells if the route may not handle some requests to its path
+// and methods
+func (rt *Route) hasMatchers() bool {
+	return len(rt.matchers) > 0 || len(rt.consumes) > 0 || len(rt.produces) > 0
+}
+
+// matches tells if all the matchers of the route accept the request
+func (rt *Route) matches(r *http.Request, ctx *Context) bool {
+	mark := len(ctx.queryParams)
+	for _, m := range rt.matchers {
+		if !m(r, ctx) {
+			ctx.queryParams = ctx.queryParams[:mark]
+			return false
+		}
+	}
+	return true
+}
+
+// Queries makes the route handle only requests with the query params. pairs
+// are the param name and a pattern for its value: empty accepts any value, a
+// text accepts only itself, and patterns with brackets like "{p:uint}" are
+// matched like path segments, with the values on Context.QueryParam. Routes
+// with the same path and methods are told apart by their queries, like
+// "action", "delete" and "action", "rename", and the route without matchers
+// handles the requests matching none of them.
+func (route *RouteBuilder) Queries(pairs ...string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if len(pairs)%2 != 0 {
+		route.err = fmt.Errorf("queries must be pairs of name and pattern")
+		return route
+	}
+
+	for i := 0; i < len(pairs); i += 2 {
+		m, err := queryMatcher(pairs[i], pairs[i+1])
+		if err != nil {
+			route.err = err
+			return route
+		}
+		route.matchers = append(route.matchers, m)
+		route.predicates = append(route.predicates, "query:"+pairs[i]+"="+pairs[i+1])
+	}
+	return route
+}
+
+func queryMatcher(key, pattern string) (MatcherFunc, error) {
+	if key == "" {
+		return nil, fmt.Errorf("query name must not be empty")
+	}
+
+	if !strings.Contains(pattern, "{") {
+		return func(r *http.Request, ctx *Context) bool {
+			vs, found := ctx.queryValues(r)[key]
+			if !found {
+				return false
+			}
+			return pattern == "" || (len(vs) > 0 && vs[0] == pattern)
+		}, nil
+	}
+
+	seg, err := newSearchNode(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %v of query %v: %v", pattern, key, err)
+	}
+	return func(r *http.Request, ctx *Context) bool {
+		vs := ctx.queryValues(r)[key]
+		return len(vs) > 0 && seg.Match(vs[0], &ctx.queryParams)
+	}, nil
+}
+
+// Headers makes the route handle only requests with the headers. pairs are
+// the header name and its value, empty accepts any value.
+func (route *RouteBuilder) Headers(pairs ...string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if len(pairs)%2 != 0 {
+		route.err = fmt.Errorf("headers must be pairs of name and value")
+		return route
+	}
+
+	for i := 0; i < len(pairs); i += 2 {
+		key, value := http.CanonicalHeaderKey(pairs[i]), pairs[i+1]
+		if key == "" {
+			route.err = fmt.Errorf("header name must not be empty")
+			return route
+		}
+		route.predicates = append(route.predicates, "header:"+key+"="+value)
+		route.matchers = append(route.matchers, func(r *http.Request, ctx *Context) bool {
+			vs, found := r.Header[key]
+			if !found {
+				return false
+			}
+			return value == "" || (len(vs) > 0 && vs[0] == value)
+		})
+	}
+	return route
+}
+
+// HeadersRegexp makes the route handle only requests with the headers. pairs
+// are the header name and a regular expression its value must match.
+func (route *RouteBuilder) HeadersRegexp(pairs ...string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if len(pairs)%2 != 0 {
+		route.err = fmt.Errorf("headers must be pairs of name and regular expression")
+		return route
+	}
+
+	for i := 0; i < len(pairs); i += 2 {
+		key := http.CanonicalHeaderKey(pairs[i])
+		if key == "" {
+			route.err = fmt.Errorf("header name must not be empty")
+			return route
+		}
+		re, err := regexp.Compile(pairs[i+1])
+		if err != nil {
+			route.err = fmt.Errorf("invalid regular expression %v of header %v: %v", pairs[i+1], key, err)
+			return route
+		}
+		route.predicates = append(route.predicates, "header:"+key+"~"+pairs[i+1])
+		route.matchers = append(route.matchers, func(r *http.Request, ctx *Context) bool {
+			vs, found := r.Header[key]
+			return found && len(vs) > 0 && re.MatchString(vs[0])
+		})
+	}
+	return route
+}
+
+// Schemes makes the route handle only requests with one of the schemes, like
+// "https". The scheme is the one on the request URL, when set, or else https
+// for TLS connections and http for the others.
+func (route *RouteBuilder) Schemes(schemes ...string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if len(schemes) == 0 {
+		route.err = fmt.Errorf("no scheme")
+		return route
+	}
+
+	accepted := make(map[string]struct{}, len(schemes))
+	for _, s := range schemes {
+		accepted[strings.ToLower(s)] = struct{}{}
+	}
+	route.predicates = append(route.predicates, "schemes:"+strings.ToLower(strings.Join(schemes, ",")))
+	route.matchers = append(route.matchers, func(r *http.Request, ctx *Context) bool {
+		_, found := accepted[requestScheme(r)]
+		return found
+	})
+	return route
+}
+
+func requestScheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// MatcherFunc makes the route handle only requests accepted by f. The routes
+// told apart only by their MatcherFuncs must be named, as their generated
+// names are the same.
+func (route *RouteBuilder) MatcherFunc(f MatcherFunc) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if f == nil {
+		route.err = fmt.Errorf("matcher must not be nil")
+		return route
+	}
+	route.matchers = append(route.matchers, f)
+	route.predicates = append(route.predicates, "func")
+	return route
+}
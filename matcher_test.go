@@ -0,0 +1,191 @@
+package smux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueries(t *testing.T) {
+	router := NewRouter()
+
+	r1, err := NewRoute().Path("/reports").Methods("GET").
+		Queries("format", "{fmt:enum(json|xml)}", "page", "{p:uint}", "debug", "", "v", "2").
+		HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx := GetSmuxContext(r.Context())
+			io.WriteString(rw, ctx.QueryParam("fmt")+" "+ctx.QueryParam("p"))
+		}).Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	router.AddRoute(r1)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/reports?format=json&page=2&debug&v=2", 200, "json 2"},
+		{"/reports?format=xml&page=10&debug=1&v=2", 200, "xml 10"},
+		{"/reports?format=csv&page=2&debug&v=2", 404, ""},
+		{"/reports?format=json&page=x&debug&v=2", 404, ""},
+		{"/reports?format=json&page=2&v=2", 404, ""},
+		{"/reports?format=json&page=2&debug&v=3", 404, ""},
+		{"/reports", 404, ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest("GET", tC.target, nil))
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if tC.code == 200 && rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestQueriesDispatch(t *testing.T) {
+	router := NewRouter()
+
+	action := func(a string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, a)
+		})
+	}
+	r1, _ := NewRoute().Path("/files/{name}").Methods("POST").Queries("action", "delete").Handler(action("delete")).Build()
+	r2, _ := NewRoute().Path("/files/{name}").Methods("POST").Queries("action", "rename", "to", "{to}").Handler(action("rename")).Build()
+	r3, _ := NewRoute().Path("/files/{name}").Methods("POST").Handler(action("upload")).Build()
+	router.SetRoutes([]*Route{r1, r2, r3})
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		target string
+		body   string
+	}{
+		{"/files/a?action=delete", "delete"},
+		{"/files/a?action=rename&to=b", "rename"},
+		{"/files/a?action=rename", "upload"},
+		{"/files/a?action=copy", "upload"},
+		{"/files/a", "upload"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest("POST", tC.target, nil))
+			if rw.Code != 200 || rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v %v", tC.body, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestQueriesErrors(t *testing.T) {
+	for _, pairs := range [][]string{{"format"}, {"", "json"}, {"page", "{p:foo}"}} {
+		_, err := NewRoute().Path("/reports").Methods("GET").Queries(pairs...).Handler(http.DefaultServeMux).Build()
+		if err == nil {
+			t.Fatalf("Queries %v must have some error", pairs)
+		}
+	}
+}
+
+func TestHeadersAndMatchers(t *testing.T) {
+	router := NewRouter()
+
+	answer := func(body string) http.HandlerFunc {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, body)
+		}
+	}
+
+	builders := []*RouteBuilder{
+		NewRoute().Name("v1").Path("/api/users").Methods("GET").HandlerFunc(answer("v1")),
+		NewRoute().Name("v2").Path("/api/users").Methods("GET").Headers("X-Api-Version", "2").HandlerFunc(answer("v2")),
+		NewRoute().Name("v3").Path("/api/users").Methods("GET").HeadersRegexp("x-api-version", `^3\.\d+$`).HandlerFunc(answer("v3")),
+		NewRoute().Name("secure").Path("/api/users").Methods("GET").Schemes("https").Headers("X-Debug", "").HandlerFunc(answer("secure")),
+		NewRoute().Name("beta").Path("/api/beta").Methods("GET").MatcherFunc(func(r *http.Request, ctx *Context) bool {
+			return r.Header.Get("X-Beta") == "on"
+		}).HandlerFunc(answer("beta")),
+	}
+	addRoutes(t, router, builders...)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		target  string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{"/api/users", nil, 200, "v1"},
+		{"/api/users", map[string]string{"X-Api-Version": "2"}, 200, "v2"},
+		{"/api/users", map[string]string{"X-Api-Version": "3.1"}, 200, "v3"},
+		{"/api/users", map[string]string{"X-Api-Version": "4"}, 200, "v1"},
+		{"/api/users", map[string]string{"X-Debug": "1"}, 200, "v1"},
+		{"https://example.com/api/users", map[string]string{"X-Debug": ""}, 200, "secure"},
+		{"/api/beta", map[string]string{"X-Beta": "on"}, 200, "beta"},
+		{"/api/beta", nil, 404, ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.target+tC.body, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tC.target, nil)
+			for k, v := range tC.headers {
+				req.Header.Set(k, v)
+			}
+			router.ServeHTTP(rw, req)
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if tC.code == 200 && rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+
+	// Only one route without matchers per path and method
+	dup, _ := NewRoute().Name("dup").Path("/api/users").Methods("GET").HandlerFunc(answer("dup")).Build()
+	router.AddRoute(dup)
+	if err := router.Compile(); err == nil {
+		t.Fatalf("Compile must fail with two routes without matchers")
+	}
+	if err := router.RemoveRoute("dup"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Removing a candidate keeps the others
+	if err := router.RemoveRoute("v2"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req.Header.Set("X-Api-Version", "2")
+	router.ServeHTTP(rw, req)
+	if rw.Body.String() != "v1" {
+		t.Fatalf("Expected v1 but was %v", rw.Body.String())
+	}
+}
+
+func TestHeadersErrors(t *testing.T) {
+	builders := []*RouteBuilder{
+		NewRoute().Headers("X-Api-Version"),
+		NewRoute().Headers("", "2"),
+		NewRoute().HeadersRegexp("X-Api-Version", "(2"),
+		NewRoute().Schemes(),
+		NewRoute().MatcherFunc(nil),
+	}
+	for i, rb := range builders {
+		if rb.GetError() == nil {
+			t.Fatalf("Builder %v must have some error", i)
+		}
+	}
+}
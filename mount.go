@@ -0,0 +1,108 @@
+package smux
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// mountMethods are the methods routed to mounted handlers
+var mountMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// Mount routes the requests to prefix and below to h, for all methods.
+// prefix may have params, like /tenants/{tenant}. h sees the rest of the path
+// on Context.RoutePath, like /users for /admin/users mounted on /admin, and
+// the params of the router. A *Router mounted routes on the rest of the path,
+// adding its params to the ones of the router. Mounted routers don't
+// redirect. Mount takes effect on Compile.
+func (router *Router) Mount(prefix string, h http.Handler) error {
+	builders, err := mountRoutes(prefix, h)
+	if err != nil {
+		return err
+	}
+
+	routes := make([]*Route, 0, len(builders))
+	for _, rb := range builders {
+		rt, err := rb.Build()
+		if err != nil {
+			return err
+		}
+		routes = append(routes, rt)
+	}
+	for _, rt := range routes {
+		router.AddRoute(rt)
+	}
+	return nil
+}
+
+// Mount routes the requests to prefix and below to h, like Router.Mount,
+// with the settings of the group. The params of the host of the group are
+// kept too.
+func (g *Group) Mount(prefix string, h http.Handler) *Group {
+	if g.err != nil {
+		return g
+	}
+	builders, err := mountRoutes(joinPath(g.prefix, prefix), h)
+	if err != nil {
+		g.err = err
+		return g
+	}
+	// The routes already have the prefix of the group
+	ng := *g
+	ng.prefix = ""
+	for _, rb := range builders {
+		ng.Route(rb)
+	}
+	g.err = ng.err
+	return g
+}
+
+// mountRoutes returns the routes of h mounted on prefix
+func mountRoutes(prefix string, h http.Handler) ([]*RouteBuilder, error) {
+	if h == nil {
+		return nil, fmt.Errorf("Handler must not be nil")
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if strings.Contains(prefix, "{*}") || (prefix != "" && !verifyPath(prefix)) {
+		return nil, fmt.Errorf("invalid mount prefix %v", prefix)
+	}
+
+	mounted := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := GetSmuxContext(r.Context())
+		routePath, pathParams, matched := ctx.RoutePath, ctx.pathParams, ctx.rest
+		defer func() {
+			ctx.RoutePath, ctx.pathParams, ctx.rest = routePath, pathParams, matched
+		}()
+
+		// The catch all has the rest of the path, without the leading slash.
+		// The rest is taken as matched, as the param may be unescaped.
+		rest := ""
+		if n := len(ctx.pathParams); n > 0 && ctx.pathParams[n-1].Key == "*" {
+			rest = ctx.rest
+			ctx.pathParams = ctx.pathParams[: n-1 : n-1]
+		}
+		ctx.RoutePath = "/" + rest
+		h.ServeHTTP(rw, r)
+	})
+
+	paths := []string{prefix + "/{*}"}
+	if prefix != "" {
+		paths = append(paths, prefix)
+	}
+
+	builders := make([]*RouteBuilder, 0, len(paths))
+	for _, p := range paths {
+		builders = append(builders, NewRoute().Path(p).Methods(mountMethods...).Handler(mounted))
+	}
+	return builders, nil
+}
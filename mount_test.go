@@ -0,0 +1,156 @@
+package smux
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	admin := NewRouter()
+	r, _ := NewRoute().Path("/users/{id:uint}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := GetSmuxContext(r.Context())
+		fmt.Fprintf(rw, "%v %v %v %v", ctx.HostParam("tenant"), ctx.PathParam("v"), ctx.PathParam("id"), ctx.RoutePath)
+	}).Build()
+	admin.AddRoute(r)
+	r, _ = NewRoute().Path("/").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "admin home")
+	}).Build()
+	admin.AddRoute(r)
+	if err := admin.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	router := NewRouter()
+	router.SetHostnames([]string{"{tenant}.local.com"})
+	r, _ = NewRoute().Path("/v{v:uint}/ping").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "pong")
+	}).Build()
+	router.AddRoute(r)
+	if err := router.Mount("/v{v:uint}/admin/", admin); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err := router.Group("/v{v:uint}", func(g *Group) {
+		g.Host("{tenant}.local.com").Group("/admin", func(g *Group) {
+			g.Mount("", admin)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = router.Mount("/files", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := GetSmuxContext(r.Context())
+		fmt.Fprintf(rw, "%v %v", r.Method, ctx.RoutePath)
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		method string
+		target string
+		code   int
+		body   string
+	}{
+		{"GET", "http://acme.local.com/v2/admin/users/7", 200, "acme 2 7 /users/7"},
+		{"GET", "/v2/admin/users/7", 200, " 2 7 /users/7"},
+		{"GET", "/v2/admin", 200, "admin home"},
+		{"GET", "/v2/admin/", 200, "admin home"},
+		{"GET", "/v2/admin/users/x", 404, ""},
+		{"POST", "/v2/admin/users/7", 405, ""},
+		{"GET", "/v2/ping", 200, "pong"},
+		{"DELETE", "/files/a/b.txt", 200, "DELETE /a/b.txt"},
+		{"GET", "/files", 200, "GET /"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.method+tC.target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(tC.method, tC.target, nil))
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if tC.code == 200 && rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestMountRestoresContext(t *testing.T) {
+	child := NewRouter()
+	r, _ := NewRoute().Path("/{name}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}).Build()
+	child.AddRoute(r)
+	if err := child.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	router := NewRouter()
+	r, _ = NewRoute().Path("/{org}/{*}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := GetSmuxContext(r.Context())
+		route := ctx.Route
+		ctx.RoutePath = "/" + ctx.PathParam("*")
+		child.ServeHTTP(rw, r)
+		if ctx.Route != route || ctx.PathParam("name") != "" || ctx.PathParam("*") != "repo" {
+			t.Errorf("Context not restored: %+v", ctx)
+		}
+		io.WriteString(rw, ctx.PathParam("org"))
+	}).Build()
+	router.AddRoute(r)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "/acme/repo", nil))
+	if rw.Body.String() != "acme" {
+		t.Fatalf("Expected acme but was %v", rw.Body.String())
+	}
+}
+
+func TestMountRawPath(t *testing.T) {
+	opts := RouterOptions{UseRawPath: true, UnescapePathValues: true}
+
+	child := NewRouter()
+	child.Options = opts
+	r, _ := NewRoute().Path("/x/{id}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := GetSmuxContext(r.Context())
+		io.WriteString(rw, ctx.PathParam("org")+" "+ctx.PathParam("id"))
+	}).Build()
+	child.AddRoute(r)
+	if err := child.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	router := NewRouter()
+	router.Options = opts
+	if err := router.Mount("/{org}", child); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	// The params of the parent are unescaped once
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "/a%2525b/x/a%2Fb", nil))
+	if rw.Code != 200 || rw.Body.String() != "a%25b a/b" {
+		t.Fatalf("Expected a%%25b a/b but was %v %v", rw.Code, rw.Body.String())
+	}
+}
+
+func TestMountErrors(t *testing.T) {
+	router := NewRouter()
+	for _, prefix := range []string{"/files/{*}", "files", "/a/{b"} {
+		if err := router.Mount(prefix, http.NotFoundHandler()); err == nil {
+			t.Fatalf("Mount on %v must have some error", prefix)
+		}
+	}
+	if err := router.Mount("/files", nil); err == nil {
+		t.Fatalf("Mount of nil must have some error")
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type RouteError struct {
@@ -22,16 +24,54 @@ func (r RouteError) Error() string {
 	return http.StatusText(r.Code)
 }
 
+// Middleware wraps a handler with another one, like the ones used by most
+// routers of the ecosystem.
+type Middleware func(http.Handler) http.Handler
+
+// chain wraps h with mws, the first middleware being the outermost one
+func chain(mws []Middleware, h http.Handler) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
 type Router struct {
+	// mu serializes the changes on routes and the compilation of the table
+	mu              sync.Mutex
 	routes          []*Route
 	hosts           []string
+	middlewares     []Middleware
 	pool            *sync.Pool
 	NotFoundHandler http.Handler
-	hostRouter      *HostRouter
+	// MethodNotAllowedHandler answers the requests to known paths without a
+	// route for the method. The allowed methods are on Context.AllowedMethods.
+	MethodNotAllowedHandler http.Handler
+	// HandleMethodNotAllowed answers 405 instead of 404 when the path is known
+	// but the method is not
+	HandleMethodNotAllowed bool
+	// HandleOPTIONS answers OPTIONS requests to known paths without an OPTIONS
+	// route with the Allow header. The Allow headers have OPTIONS too.
+	HandleOPTIONS bool
+	// Options configures the redirects and the matching of paths
+	Options RouterOptions
+	// CORS, when set, answers the preflight requests to known paths and adds
+	// the CORS headers to the requests handled by routes
+	CORS *CORS
+	// table holds the *HostRouter in use. It is replaced as a whole on
+	// Compile, so requests being served keep the table they started with.
+	table atomic.Value
+	// compiled maps the route names to the routes in use on the table
+	compiled map[string]*Route
 }
 
 func NewRouter() *Router {
-	r := &Router{pool: &sync.Pool{}, routes: []*Route{}}
+	r := &Router{
+		pool:                   &sync.Pool{},
+		routes:                 []*Route{},
+		HandleMethodNotAllowed: true,
+		HandleOPTIONS:          true,
+	}
 	r.pool.New = func() interface{} {
 		return &Context{}
 	}
@@ -39,51 +79,199 @@ func NewRouter() *Router {
 }
 
 func (router *Router) AddRoute(route *Route) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
 	router.routes = append(router.routes, route)
 }
 
 func (router *Router) SetRoutes(routes []*Route) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
 	router.routes = routes
 }
 
-func (router Router) Routes() []*Route {
+func (router *Router) Routes() []*Route {
+	router.mu.Lock()
+	defer router.mu.Unlock()
 	return router.routes
 }
 
 func (router *Router) SetHostnames(hostnames []string) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
 	router.hosts = hostnames
 }
 
+// Use appends middlewares applied to every route of the router.
+// They run before the route's own middlewares and only take effect on Compile.
+func (router *Router) Use(mws ...Middleware) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.middlewares = append(router.middlewares, mws...)
+}
+
+// compile returns a copy of r with the handler wrapped by the middlewares.
+// The copy is used on the table, so the Route of a table in use is never modified.
+func (router *Router) compile(r *Route) *Route {
+	mws := make([]Middleware, 0, len(router.middlewares)+len(r.middlewares))
+	mws = append(mws, router.middlewares...)
+	mws = append(mws, r.middlewares...)
+
+	rc := *r
+	rc.chain = chain(mws, r.handler)
+	rc.paramtypes = routeParamTypes(r.path)
+	rc.paramnames = routeParamNames(r.path)
+	return &rc
+}
+
+// Compile builds a new table from the routes and hostnames and puts it in use.
+// It's safe to call Compile while serving requests: on error, the table in
+// use is kept.
 func (router *Router) Compile() error {
-	router.hostRouter = NewHostRouter()
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	if err := router.Options.verify(); err != nil {
+		return err
+	}
+
+	hostRouter := NewHostRouter()
 	for _, hn := range router.hosts {
-		err := router.hostRouter.AddHostname(hn)
+		err := hostRouter.AddHostname(hn)
 		if err != nil {
 			return err
 		}
 	}
+	compiled := make(map[string]*Route, len(router.routes))
 	for _, r := range router.routes {
-		err := router.hostRouter.AddRoute(r)
+		if r == nil {
+			continue
+		}
+		if _, found := compiled[r.name]; found {
+			return fmt.Errorf("route name %v already in use", r.name)
+		}
+		rc := router.compile(r)
+		err := hostRouter.AddRoute(rc)
 		if err != nil {
 			return err
 		}
+		compiled[r.name] = rc
 	}
+
+	router.table.Store(hostRouter)
+	router.compiled = compiled
 	return nil
 }
 
+func (router *Router) indexOf(name string) int {
+	for i, r := range router.routes {
+		if r != nil && r.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// RemoveRoute removes the route named name. If the router is compiled, the
+// table in use is updated without recompiling the other routes.
+func (router *Router) RemoveRoute(name string) error {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	i := router.indexOf(name)
+	if i < 0 {
+		return fmt.Errorf("route %v not found", name)
+	}
+
+	if rc, found := router.compiled[name]; found {
+		hostRouter, err := router.hostRouter().without(rc)
+		if err != nil {
+			return err
+		}
+		router.table.Store(hostRouter)
+		delete(router.compiled, name)
+	}
+
+	routes := make([]*Route, 0, len(router.routes)-1)
+	routes = append(routes, router.routes[:i]...)
+	router.routes = append(routes, router.routes[i+1:]...)
+	return nil
+}
+
+// ReplaceRoute replaces the route with the same name of r, or adds r if
+// there is none. If the router is compiled, the table in use is updated
+// without recompiling the other routes.
+func (router *Router) ReplaceRoute(r *Route) error {
+	if r == nil {
+		return fmt.Errorf("route must not be nil")
+	}
+
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	if hostRouter := router.hostRouter(); hostRouter != nil {
+		var err error
+		if old, found := router.compiled[r.name]; found {
+			hostRouter, err = hostRouter.without(old)
+			if err != nil {
+				return err
+			}
+		}
+		rc := router.compile(r)
+		hostRouter, err = hostRouter.with(rc)
+		if err != nil {
+			return err
+		}
+		router.table.Store(hostRouter)
+		router.compiled[r.name] = rc
+	}
+
+	routes := make([]*Route, len(router.routes), len(router.routes)+1)
+	copy(routes, router.routes)
+	if i := router.indexOf(r.name); i >= 0 {
+		routes[i] = r
+	} else {
+		routes = append(routes, r)
+	}
+	router.routes = routes
+	return nil
+}
+
+func (router *Router) hostRouter() *HostRouter {
+	h, _ := router.table.Load().(*HostRouter)
+	return h
+}
+
 func (router *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	// A router handling a route of another router, like a mounted one, shares
+	// its context
+	if parent, ok := r.Context().Value(ParamContext).(*Context); ok && parent.bound {
+		saved := *parent
+		defer func() {
+			*parent = saved
+		}()
+		router.serve(rw, r, parent)
+		return
+	}
+
 	ctx := router.pool.Get().(*Context)
-	defer router.pool.Put(ctx)
 	ctx.Reset()
-
-	// Clean up the path following setted configuration
-
-	if router.hostRouter == nil {
-		if router.NotFoundHandler != nil {
-			router.NotFoundHandler.ServeHTTP(rw, r)
-		} else {
-			http.NotFoundHandler().ServeHTTP(rw, r)
+	defer func() {
+		// Handlers may retain the context after returning, so only the contexts
+		// never handed to them are reused
+		if !ctx.bound {
+			router.pool.Put(ctx)
 		}
+	}()
+	router.serve(rw, r, ctx)
+}
+
+// serve routes r with ctx. The path params and host params already on ctx are
+// kept, the ones of the route are appended.
+func (router *Router) serve(rw http.ResponseWriter, r *http.Request, ctx *Context) {
+	hostRouter := router.hostRouter()
+	if hostRouter == nil {
+		router.notFound(rw, r)
 		return
 	}
 	hostname := r.Host
@@ -93,49 +281,117 @@ func (router *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	routePath := ctx.RoutePath
 	if routePath == "" {
-		if r.URL.RawPath != "" {
-			routePath = r.URL.RawPath
-		} else {
-			routePath = r.URL.Path
+		routePath = router.Options.requestPath(r)
+	}
+
+	ctx.fold = router.Options.CaseInsensitive
+	mark := len(ctx.pathParams)
+	n := hostRouter.Get(hostname, routePath, ctx)
+	if n == nil {
+		// Tries the path cleaned or with the trailing slash toggled. The
+		// routers sharing a context don't know the whole path to redirect to.
+		if ctx.bound || !router.redirect(rw, r, hostRouter, hostname, routePath, ctx) {
+			router.notFound(rw, r)
 		}
+		return
 	}
 
-	n := router.hostRouter.Get(hostname, routePath, ctx)
-	if n != nil {
-		methodRouters := n.Methods()
-		rt := methodRouters[r.Method]
-		if rt == nil {
-			// Method not allowed
-			ms := make([]string, len(methodRouters))
-			i := 0
-			for m := range methodRouters {
-				ms[i] = m
-				i += 1
-			}
-			rw.Header().Set("Allow", strings.Join(ms, ","))
-			rw.WriteHeader(http.StatusMethodNotAllowed)
-			rw.Write([]byte(http.StatusText(http.StatusMethodNotAllowed)))
-		} else {
-			ctx.Route = rt
-			ctx.handler = rt.handler
-			ctx.RoutePath = routePath
+	if router.CORS != nil && isPreflight(r) {
+		router.CORS.preflight(rw, r, n.AllowedMethods())
+		return
+	}
 
-			r = r.WithContext((*directContext)(ctx))
-			ctx.handler.ServeHTTP(rw, r)
+	rts := n.Routes(r.Method)
+	if len(rts) == 0 {
+		ctx.allowed = n.AllowedMethods()
+		if r.Method == http.MethodOptions && router.HandleOPTIONS {
+			rw.Header().Set("Allow", router.allow(ctx))
+			rw.WriteHeader(http.StatusNoContent)
+		} else if router.HandleMethodNotAllowed {
+			router.methodNotAllowed(rw, r, ctx)
+		} else {
+			router.notFound(rw, r)
 		}
-	} else if router.NotFoundHandler != nil {
+		return
+	}
+
+	rt, status := selectRoute(rts, r, ctx)
+	switch status {
+	case http.StatusNotFound:
+		router.notFound(rw, r)
+		return
+	case http.StatusUnsupportedMediaType, http.StatusNotAcceptable:
+		rw.WriteHeader(status)
+		rw.Write([]byte(http.StatusText(status)))
+		return
+	}
+	if len(rt.produces) > 0 {
+		rw.Header().Add("Vary", "Accept")
+	}
+
+	rt.nameParams(ctx.pathParams[mark:])
+	ctx.Route = rt
+	ctx.handler = rt.chain
+	ctx.RoutePath = routePath
+	router.Options.unescapePathParams(ctx, mark)
+
+	if router.CORS != nil {
+		router.CORS.decorate(rw, r)
+	}
+
+	ctx.handler.ServeHTTP(rw, ctx.bind(r))
+}
+
+func (router *Router) notFound(rw http.ResponseWriter, r *http.Request) {
+	if router.NotFoundHandler != nil {
 		router.NotFoundHandler.ServeHTTP(rw, r)
 	} else {
 		http.NotFoundHandler().ServeHTTP(rw, r)
 	}
 }
 
+// allow returns the Allow header of the path, which has OPTIONS too when it's
+// answered by the router
+func (router *Router) allow(ctx *Context) string {
+	allowed := ctx.allowed
+	if i := sort.SearchStrings(allowed, http.MethodOptions); router.HandleOPTIONS && (i == len(allowed) || allowed[i] != http.MethodOptions) {
+		allowed = append(allowed[:len(allowed):len(allowed)], http.MethodOptions)
+		sort.Strings(allowed)
+	}
+	return strings.Join(allowed, ",")
+}
+
+func (router *Router) methodNotAllowed(rw http.ResponseWriter, r *http.Request, ctx *Context) {
+	rw.Header().Set("Allow", router.allow(ctx))
+	if router.MethodNotAllowedHandler != nil {
+		router.MethodNotAllowedHandler.ServeHTTP(rw, ctx.bind(r))
+		return
+	}
+	rw.WriteHeader(http.StatusMethodNotAllowed)
+	rw.Write([]byte(http.StatusText(http.StatusMethodNotAllowed)))
+}
+
 type Route struct {
-	name    string
-	host    string
-	path    string
-	methods map[string]struct{}
-	handler http.Handler
+	name        string
+	host        string
+	path        string
+	methods     map[string]struct{}
+	handler     http.Handler
+	middlewares []Middleware
+	// matchers must all accept the request for the route to handle it
+	matchers []MatcherFunc
+	// media types of the Content-Type and Accept headers handled
+	consumes []string
+	produces []string
+	metadata map[string]string
+	doc      routeDoc
+	// handler wrapped by all the middlewares, built on Router.Compile
+	chain http.Handler
+	// types of the path params, built on Router.Compile
+	paramtypes map[string]string
+	// names of the path params in the order they are found, built on
+	// Router.Compile
+	paramnames []string
 }
 
 func (r Route) Name() string {
@@ -150,6 +406,11 @@ func (r Route) Path() string {
 	return r.path
 }
 
+// Metadata returns the value of key set with RouteBuilder.Metadata
+func (r Route) Metadata(key string) string {
+	return r.metadata[key]
+}
+
 func (r Route) Methods() []string {
 	ms := make([]string, len(r.methods))
 	i := 0
@@ -157,16 +418,25 @@ func (r Route) Methods() []string {
 		ms[i] = m
 		i += 1
 	}
+	sort.Strings(ms)
 	return ms
 }
 
 type RouteBuilder struct {
-	name    string
-	host    string
-	path    string
-	methods map[string]struct{}
-	handler http.Handler
-	err     error
+	name        string
+	host        string
+	path        string
+	methods     map[string]struct{}
+	handler     http.Handler
+	middlewares []Middleware
+	matchers    []MatcherFunc
+	// predicates describe the matchers on the generated name
+	predicates []string
+	consumes   []string
+	produces   []string
+	metadata   map[string]string
+	doc        routeDoc
+	err        error
 }
 
 func NewRoute() *RouteBuilder {
@@ -192,8 +462,14 @@ func (route RouteBuilder) mkname() string {
 		methods[i] = m
 		i += 1
 	}
+	sort.Strings(methods)
 
-	return fmt.Sprintf("[%s] %s%s", strings.Join(methods, " "), route.host, route.path)
+	name := fmt.Sprintf("[%s] %s%s", strings.Join(methods, " "), route.host, route.path)
+	// Routes with the same path and methods are told apart by their matchers
+	if len(route.predicates) > 0 {
+		name += " " + strings.Join(route.predicates, " ")
+	}
+	return name
 }
 
 func (route *RouteBuilder) Host(h string) *RouteBuilder {
@@ -212,8 +488,8 @@ func (route *RouteBuilder) Path(p string) *RouteBuilder {
 	if route.err != nil {
 		return route
 	}
-	if !verifyPath(p) {
-		route.err = fmt.Errorf("invalid path")
+	if _, err := ParsePath(p); err != nil {
+		route.err = err
 		return route
 	}
 	route.path = p
@@ -260,6 +536,35 @@ func (route *RouteBuilder) HandlerFunc(handler func(http.ResponseWriter, *http.R
 	return route
 }
 
+// Middleware appends middlewares applied only to this route, after the
+// router's ones.
+func (route *RouteBuilder) Middleware(mws ...Middleware) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	for _, mw := range mws {
+		if mw == nil {
+			route.err = fmt.Errorf("Middleware must not be nil")
+			return route
+		}
+	}
+	route.middlewares = append(route.middlewares, mws...)
+	return route
+}
+
+// Metadata sets the value of key on the route, for middlewares and tools
+// reading the routes. It doesn't change the routing.
+func (route *RouteBuilder) Metadata(key, value string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if route.metadata == nil {
+		route.metadata = make(map[string]string)
+	}
+	route.metadata[key] = value
+	return route
+}
+
 func (route RouteBuilder) GetError() error {
 	return route.err
 }
@@ -273,20 +578,45 @@ func (r RouteBuilder) Build() (*Route, error) {
 		return nil, fmt.Errorf("Handler must not be nil")
 	}
 
+	var metadata map[string]string
+	if len(r.metadata) > 0 {
+		metadata = make(map[string]string, len(r.metadata))
+		for k, v := range r.metadata {
+			metadata[k] = v
+		}
+	}
+
 	return &Route{
-		name:    r.mkname(),
-		host:    r.host,
-		path:    r.path,
-		methods: r.methods,
-		handler: r.handler,
+		name:        r.mkname(),
+		host:        r.host,
+		path:        r.path,
+		methods:     r.methods,
+		handler:     r.handler,
+		middlewares: append([]Middleware(nil), r.middlewares...),
+		matchers:    append([]MatcherFunc(nil), r.matchers...),
+		consumes:    append([]string(nil), r.consumes...),
+		produces:    append([]string(nil), r.produces...),
+		metadata:    metadata,
+		doc:         r.doc.clone(),
 	}, nil
 }
 
 type MatchResult interface {
 	Methods() map[string]*Route
+	// Routes returns the candidate routes of the method, in the order they
+	// must be tried
+	Routes(method string) []*Route
+	AllowedMethods() []string
 }
 
 type PathRouter interface {
 	Add(*Route) error
+	Remove(*Route) error
 	Get(string, *Context) MatchResult
+	// Fork returns a copy that can be changed to add or remove the route
+	// without affecting the original
+	Fork(*Route) PathRouter
+	// Walk calls the function with the segments of each path with routes
+	Walk(func(segments []string, m MatchResult) error) error
+	Dump() []NodeDump
 }
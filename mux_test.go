@@ -8,6 +8,22 @@ import (
 	"testing"
 )
 
+// addRoutes builds and adds the routes of builders to router. The builders
+// without handler get http.NotFoundHandler.
+func addRoutes(t *testing.T, router *Router, builders ...*RouteBuilder) {
+	t.Helper()
+	for _, rb := range builders {
+		if rb.handler == nil {
+			rb.Handler(http.NotFoundHandler())
+		}
+		r, err := rb.Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		router.AddRoute(r)
+	}
+}
+
 func TestAddGetUser(t *testing.T) {
 	router := NewRouter()
 
@@ -48,11 +64,11 @@ func TestAddGetUser(t *testing.T) {
 	if rw.Code != http.StatusMethodNotAllowed {
 		t.Fatal("Must be method not allowed")
 	}
-	if rw.Result().Header.Get("Allow") != "GET,HEAD" && rw.Result().Header.Get("Allow") != "HEAD,GET" {
-		t.Fatal("Expect GET,HEAD allowed")
+	if rw.Result().Header.Get("Allow") != "GET,HEAD,OPTIONS" {
+		t.Fatal("Expect GET,HEAD,OPTIONS allowed")
 	}
-	if len(strings.Split(rw.Result().Header.Get("Allow"), ",")) != 2 {
-		t.Fatal("Expected only 2 headers")
+	if len(strings.Split(rw.Result().Header.Get("Allow"), ",")) != 3 {
+		t.Fatal("Expected only 3 headers")
 	}
 
 	req = httptest.NewRequest("GET", "/users/", nil)
@@ -63,3 +79,320 @@ func TestAddGetUser(t *testing.T) {
 		t.Fatalf("Must be not found but was %v - %v", rw.Code, http.StatusText(rw.Code))
 	}
 }
+
+func TestMiddlewareOrder(t *testing.T) {
+	router := NewRouter()
+
+	mark := func(s string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				io.WriteString(rw, s)
+				next.ServeHTTP(rw, r)
+			})
+		}
+	}
+
+	router.Use(mark("r1-"), mark("r2-"))
+
+	r1, err := NewRoute().Path("/users").Methods("GET").Middleware(mark("m1-"), mark("m2-")).HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "handler")
+	}).Build()
+	if err != nil {
+		t.Fatalf("Found error: %v", err)
+	}
+	router.AddRoute(r1)
+
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+
+	if body := rw.Body.String(); body != "r1-r2-m1-m2-handler" {
+		t.Fatalf("Wrong middleware order: %v", body)
+	}
+}
+
+func TestNilMiddleware(t *testing.T) {
+	_, err := NewRoute().Path("/users").Methods("GET").Middleware(nil).Handler(http.DefaultServeMux).Build()
+	if err == nil {
+		t.Fatal("Must have some error")
+	}
+}
+
+func TestCompileWhileServing(t *testing.T) {
+	router := NewRouter()
+
+	r1, _ := NewRoute().Path("/v1").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "v1")
+	}).Build()
+	router.AddRoute(r1)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			r2, _ := NewRoute().Path("/v2").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				io.WriteString(rw, "v2")
+			}).Build()
+			router.SetRoutes([]*Route{r1, r2})
+			if err := router.Compile(); err != nil {
+				t.Errorf("Error compiling: %v", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest("GET", "/v1", nil))
+		if rw.Code != 200 || rw.Body.String() != "v1" {
+			t.Fatalf("Unexpected response %v %v", rw.Code, rw.Body.String())
+		}
+	}
+	<-done
+
+	// A failed compilation keeps the table in use
+	r3, _ := NewRoute().Path("/v1").Methods("GET").Handler(http.NotFoundHandler()).Build()
+	router.AddRoute(r3)
+	if err := router.Compile(); err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "/v2", nil))
+	if rw.Code != 200 || rw.Body.String() != "v2" {
+		t.Fatalf("Unexpected response %v %v", rw.Code, rw.Body.String())
+	}
+}
+
+func TestRemoveAndReplaceRoute(t *testing.T) {
+	router := NewRouter()
+
+	write := func(s string) func(http.ResponseWriter, *http.Request) {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, s)
+		}
+	}
+
+	r1, _ := NewRoute().Name("users").Path("/users").Methods("GET").HandlerFunc(write("users")).Build()
+	r2, _ := NewRoute().Name("flag").Path("/flag").Methods("GET").HandlerFunc(write("flag")).Build()
+	router.SetRoutes([]*Route{r1, r2})
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	get := func(path string) (int, string) {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest("GET", path, nil))
+		return rw.Code, rw.Body.String()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := router.RemoveRoute("flag"); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			if err := router.ReplaceRoute(r2); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if code, body := get("/users"); code != 200 || body != "users" {
+			t.Fatalf("Unexpected response %v %v", code, body)
+		}
+	}
+	<-done
+
+	if err := router.RemoveRoute("flag"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if code, _ := get("/flag"); code != 404 {
+		t.Fatalf("Must be not found but was %v", code)
+	}
+	if err := router.RemoveRoute("flag"); err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	r3, _ := NewRoute().Name("users").Path("/people").Methods("GET").HandlerFunc(write("people")).Build()
+	if err := router.ReplaceRoute(r3); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if code, _ := get("/users"); code != 404 {
+		t.Fatalf("Must be not found but was %v", code)
+	}
+	if code, body := get("/people"); code != 200 || body != "people" {
+		t.Fatalf("Unexpected response %v %v", code, body)
+	}
+	if len(router.Routes()) != 1 {
+		t.Fatalf("Must have 1 route but has %v", len(router.Routes()))
+	}
+
+	// The table in use is kept on errors
+	r4, _ := NewRoute().Name("other").Path("/people").Methods("GET").HandlerFunc(write("other")).Build()
+	if err := router.ReplaceRoute(r4); err == nil {
+		t.Fatal("Must have some error")
+	}
+	if code, body := get("/people"); code != 200 || body != "people" {
+		t.Fatalf("Unexpected response %v %v", code, body)
+	}
+
+	// The changes are kept on recompilation
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+	if code, body := get("/people"); code != 200 || body != "people" {
+		t.Fatalf("Unexpected response %v %v", code, body)
+	}
+}
+
+func TestDuplicatedRouteName(t *testing.T) {
+	router := NewRouter()
+
+	r1, _ := NewRoute().Name("users").Path("/users").Methods("GET").Handler(http.DefaultServeMux).Build()
+	r2, _ := NewRoute().Name("users").Path("/people").Methods("GET").Handler(http.DefaultServeMux).Build()
+	router.SetRoutes([]*Route{r1, r2})
+	if err := router.Compile(); err == nil {
+		t.Fatal("Must have some error")
+	}
+}
+
+func TestUnnamedRoutesWithMatchers(t *testing.T) {
+	router := NewRouter()
+
+	version := func(v string) http.HandlerFunc {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, v)
+		}
+	}
+	addRoutes(t, router,
+		NewRoute().Path("/x").Methods("GET").Headers("X-Version", "1").HandlerFunc(version("v1")),
+		NewRoute().Path("/x").Methods("GET").Headers("X-Version", "2").HandlerFunc(version("v2")),
+		NewRoute().Path("/x").Methods("GET").HandlerFunc(version("default")),
+	)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		version string
+		body    string
+	}{
+		{"1", "v1"},
+		{"2", "v2"},
+		{"", "default"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.body, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/x", nil)
+			if tC.version != "" {
+				req.Header.Set("X-Version", tC.version)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+			if rw.Code != 200 || rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v %v", tC.body, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestOptionsAndMethodNotAllowed(t *testing.T) {
+	router := NewRouter()
+
+	r1, _ := NewRoute().Path("/users").Methods("POST", "GET", "DELETE").Handler(http.DefaultServeMux).Build()
+	r2, _ := NewRoute().Path("/options").Methods("OPTIONS").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "options")
+	}).Build()
+	router.SetRoutes([]*Route{r1, r2})
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("OPTIONS", "/users", nil))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("Must be no content but was %v", rw.Code)
+	}
+	if allow := rw.Header().Get("Allow"); allow != "DELETE,GET,OPTIONS,POST" {
+		t.Fatalf("Wrong Allow header %v", allow)
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("OPTIONS", "/options", nil))
+	if rw.Code != http.StatusOK || rw.Body.String() != "options" {
+		t.Fatalf("Must call the OPTIONS route but was %v", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("OPTIONS", "/unknown", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("Must be not found but was %v", rw.Code)
+	}
+
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := GetSmuxContext(r.Context())
+		rw.WriteHeader(http.StatusTeapot)
+		io.WriteString(rw, strings.Join(ctx.AllowedMethods(), " "))
+	})
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("PUT", "/users", nil))
+	if rw.Code != http.StatusTeapot || rw.Body.String() != "DELETE GET POST" {
+		t.Fatalf("Must call MethodNotAllowedHandler but was %v %v", rw.Code, rw.Body.String())
+	}
+	if allow := rw.Header().Get("Allow"); allow != "DELETE,GET,OPTIONS,POST" {
+		t.Fatalf("Wrong Allow header %v", allow)
+	}
+
+	// OPTIONS is allowed only when the router answers it
+	router.HandleOPTIONS = false
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("PUT", "/users", nil))
+	if allow := rw.Header().Get("Allow"); allow != "DELETE,GET,POST" {
+		t.Fatalf("Wrong Allow header %v", allow)
+	}
+
+	router.HandleMethodNotAllowed = false
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("OPTIONS", "/users", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("Must be not found but was %v", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("PUT", "/users", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("Must be not found but was %v", rw.Code)
+	}
+}
+
+func TestHostParams(t *testing.T) {
+	router := NewRouter()
+	router.SetHostnames([]string{"{tenant}.example.com"})
+
+	r1, _ := NewRoute().Host("{tenant}.example.com").Path("/").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, GetSmuxContext(r.Context()).HostParam("tenant"))
+	}).Build()
+	router.AddRoute(r1)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "http://acme.example.com:8080/", nil))
+	if rw.Code != 200 || rw.Body.String() != "acme" {
+		t.Fatalf("Unexpected response %v %v", rw.Code, rw.Body.String())
+	}
+}
@@ -0,0 +1,235 @@
+package smux
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediarange is a media type of an Accept header, like text/* with its
+// quality
+type mediarange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+func splitMediaType(t string) (string, string, bool) {
+	i := strings.IndexByte(t, '/')
+	if i <= 0 || i == len(t)-1 {
+		return "", "", false
+	}
+	return t[:i], t[i+1:], true
+}
+
+// parseMediaType returns the media type of v without params, in lower case
+func parseMediaType(v string) (string, error) {
+	t, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return "", err
+	}
+	if _, _, ok := splitMediaType(t); !ok {
+		return "", fmt.Errorf("invalid media type %v", v)
+	}
+	return t, nil
+}
+
+// parseAccept returns the media ranges of the Accept header. Ranges with
+// invalid media types or qualities are left out, and no header accepts any
+// type.
+func parseAccept(header string) []mediarange {
+	if strings.TrimSpace(header) == "" {
+		return []mediarange{{typ: "*", subtype: "*", q: 1}}
+	}
+
+	var ranges []mediarange
+	for _, v := range strings.Split(header, ",") {
+		t, params, err := mime.ParseMediaType(v)
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := splitMediaType(t)
+		if !ok || (typ == "*" && subtype != "*") {
+			continue
+		}
+		q := 1.0
+		if qv, found := params["q"]; found {
+			q, err = strconv.ParseFloat(qv, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediarange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// matchMediaType tells if the media type t is on the range typ/subtype, where
+// both may be *
+func matchMediaType(typ, subtype, t string) bool {
+	ttyp, tsubtype, _ := splitMediaType(t)
+	return (typ == "*" || typ == ttyp) && (subtype == "*" || subtype == tsubtype)
+}
+
+// quality returns the quality of the media type t on the ranges, given by the
+// most specific range with t
+func quality(ranges []mediarange, t string) float64 {
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		if !matchMediaType(r.typ, r.subtype, t) {
+			continue
+		}
+		s := 0
+		if r.typ != "*" {
+			s += 1
+		}
+		if r.subtype != "*" {
+			s += 1
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// accepts tells if the route consumes the Content-Type of the request
+func (rt *Route) accepts(r *http.Request) bool {
+	if len(rt.consumes) == 0 {
+		return true
+	}
+	t, err := parseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, c := range rt.consumes {
+		typ, subtype, _ := splitMediaType(c)
+		if matchMediaType(typ, subtype, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// produce returns the type produced by the route with the best quality on
+// the ranges, the first one on ties
+func (rt *Route) produce(ranges []mediarange) (string, float64) {
+	best, bestq := "", 0.0
+	for _, p := range rt.produces {
+		if q := quality(ranges, p); q > bestq {
+			best, bestq = p, q
+		}
+	}
+	return best, bestq
+}
+
+// selectRoute returns the candidate route handling the request. The routes
+// must be accepted by their matchers and consume the Content-Type of the
+// request. Then the route producing the type with the best quality on the
+// Accept header is taken, or the first route without Produces when none
+// produces an accepted type. When no route is found the status tells why:
+// 404 for the matchers, 415 for the Content-Type and 406 for the Accept.
+func selectRoute(rts []*Route, r *http.Request, ctx *Context) (*Route, int) {
+	mark := len(ctx.queryParams)
+	status := http.StatusNotFound
+
+	var ranges []mediarange
+	var best, fallback *Route
+	negotiated, bestq := "", 0.0
+	for _, rt := range rts {
+		if !rt.matches(r, ctx) {
+			continue
+		}
+		ctx.queryParams = ctx.queryParams[:mark]
+
+		if !rt.accepts(r) {
+			if status == http.StatusNotFound {
+				status = http.StatusUnsupportedMediaType
+			}
+			continue
+		}
+		status = http.StatusNotAcceptable
+
+		if len(rt.produces) == 0 {
+			if fallback == nil {
+				fallback = rt
+			}
+			continue
+		}
+		if ranges == nil {
+			ranges = parseAccept(r.Header.Get("Accept"))
+		}
+		if t, q := rt.produce(ranges); q > bestq {
+			best, negotiated, bestq = rt, t, q
+		}
+	}
+
+	if best == nil {
+		best, negotiated = fallback, ""
+	}
+	if best == nil {
+		return nil, status
+	}
+
+	// Fills the query params of the route taken
+	best.matches(r, ctx)
+	ctx.negotiated = negotiated
+	return best, http.StatusOK
+}
+
+// Consumes makes the route handle only requests whose Content-Type is one of
+// the types, like "application/json". Types may have wildcards, like
+// "image/*". Requests without Content-Type are not consumed.
+func (route *RouteBuilder) Consumes(types ...string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if len(types) == 0 {
+		route.err = fmt.Errorf("no media type")
+		return route
+	}
+
+	n := len(route.consumes)
+	for _, v := range types {
+		t, err := parseMediaType(v)
+		if err != nil {
+			route.err = fmt.Errorf("invalid media type %v", v)
+			return route
+		}
+		if typ, subtype, _ := splitMediaType(t); typ == "*" && subtype != "*" {
+			route.err = fmt.Errorf("invalid media type %v", v)
+			return route
+		}
+		route.consumes = append(route.consumes, t)
+	}
+	route.predicates = append(route.predicates, "consumes:"+strings.Join(route.consumes[n:], ","))
+	return route
+}
+
+// Produces makes the route handle only requests accepting one of the types,
+// like "application/json", following the qualities of the Accept header.
+// Requests without Accept take any type. The type chosen is on
+// Context.NegotiatedType.
+func (route *RouteBuilder) Produces(types ...string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if len(types) == 0 {
+		route.err = fmt.Errorf("no media type")
+		return route
+	}
+
+	n := len(route.produces)
+	for _, v := range types {
+		t, err := parseMediaType(v)
+		if err != nil || strings.Contains(t, "*") {
+			route.err = fmt.Errorf("invalid media type %v", v)
+			return route
+		}
+		route.produces = append(route.produces, t)
+	}
+	route.predicates = append(route.predicates, "produces:"+strings.Join(route.produces[n:], ","))
+	return route
+}
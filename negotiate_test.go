@@ -0,0 +1,115 @@
+package smux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiation(t *testing.T) {
+	router := NewRouter()
+
+	answer := func(body string) http.HandlerFunc {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			ctx := GetSmuxContext(r.Context())
+			io.WriteString(rw, body+" "+ctx.NegotiatedType())
+		}
+	}
+
+	builders := []*RouteBuilder{
+		NewRoute().Name("json").Path("/users").Methods("GET").Produces("application/json").HandlerFunc(answer("json")),
+		NewRoute().Name("xml").Path("/users").Methods("GET").Produces("application/xml", "text/xml").HandlerFunc(answer("xml")),
+		NewRoute().Name("create-json").Path("/users").Methods("POST").Consumes("application/json").HandlerFunc(answer("create-json")),
+		NewRoute().Name("create-proto").Path("/users").Methods("POST").Consumes("application/protobuf").Produces("application/protobuf").HandlerFunc(answer("create-proto")),
+		NewRoute().Name("upload").Path("/images").Methods("PUT").Consumes("image/*").HandlerFunc(answer("upload")),
+		NewRoute().Name("report").Path("/report").Methods("GET").Produces("text/csv").HandlerFunc(answer("csv")),
+		NewRoute().Name("report-any").Path("/report").Methods("GET").HandlerFunc(answer("any")),
+	}
+	addRoutes(t, router, builders...)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		method      string
+		target      string
+		contentType string
+		accept      string
+		code        int
+		body        string
+	}{
+		{"GET", "/users", "", "", 200, "json application/json"},
+		{"GET", "/users", "", "application/xml", 200, "xml application/xml"},
+		{"GET", "/users", "", "text/*", 200, "xml text/xml"},
+		{"GET", "/users", "", "application/json;q=0.5, application/xml", 200, "xml application/xml"},
+		{"GET", "/users", "", "application/*;q=0.2, application/json;q=0", 200, "xml application/xml"},
+		{"GET", "/users", "", "*/*;q=0.1, application/json", 200, "json application/json"},
+		{"GET", "/users", "", "image/png", 406, ""},
+		{"GET", "/users", "", "application/json;q=0", 406, ""},
+		{"POST", "/users", "application/json; charset=utf-8", "", 200, "create-json "},
+		{"POST", "/users", "application/protobuf", "", 200, "create-proto application/protobuf"},
+		{"POST", "/users", "application/protobuf", "application/json", 406, ""},
+		{"POST", "/users", "text/plain", "", 415, ""},
+		{"POST", "/users", "", "", 415, ""},
+		{"PUT", "/images", "image/png", "", 200, "upload "},
+		{"PUT", "/images", "text/plain", "", 415, ""},
+		{"GET", "/report", "", "text/csv", 200, "csv text/csv"},
+		{"GET", "/report", "", "application/json", 200, "any "},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.method+tC.target+tC.contentType+tC.accept, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(tC.method, tC.target, nil)
+			if tC.contentType != "" {
+				req.Header.Set("Content-Type", tC.contentType)
+			}
+			if tC.accept != "" {
+				req.Header.Set("Accept", tC.accept)
+			}
+			router.ServeHTTP(rw, req)
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if tC.code == 200 && rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestNegotiationErrors(t *testing.T) {
+	builders := []*RouteBuilder{
+		NewRoute().Consumes(),
+		NewRoute().Consumes("json"),
+		NewRoute().Consumes("*/json"),
+		NewRoute().Produces(),
+		NewRoute().Produces("application/*"),
+		NewRoute().Produces("text html"),
+	}
+	for i, rb := range builders {
+		if rb.GetError() == nil {
+			t.Fatalf("Builder %v must have some error", i)
+		}
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	ranges := parseAccept("text/html, text/*;q=0.3, */*;q=0.1, image/png;q=2, bad")
+	if len(ranges) != 3 {
+		t.Fatalf("Expected 3 ranges but was %v", ranges)
+	}
+	testCases := []struct {
+		t string
+		q float64
+	}{
+		{"text/html", 1},
+		{"text/plain", 0.3},
+		{"image/png", 0.1},
+	}
+	for _, tC := range testCases {
+		if q := quality(ranges, tC.t); q != tC.q {
+			t.Fatalf("Expected quality %v of %v but was %v", tC.q, tC.t, q)
+		}
+	}
+}
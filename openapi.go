@@ -0,0 +1,405 @@
+package smux
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI is an OpenAPI 3 document. Only the fields generated from the routes
+// are modeled.
+type OpenAPI struct {
+	OpenAPI string                      `json:"openapi"`
+	Info    OpenAPIInfo                 `json:"info"`
+	Servers []OpenAPIServer             `json:"servers,omitempty"`
+	Paths   map[string]*OpenAPIPathItem `json:"paths"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL         string                           `json:"url"`
+	Description string                           `json:"description,omitempty"`
+	Variables   map[string]OpenAPIServerVariable `json:"variables,omitempty"`
+}
+
+type OpenAPIServerVariable struct {
+	Default     string   `json:"default"`
+	Enum        []string `json:"enum,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// OpenAPIPathItem has the operations of a path by method in lower case, like
+// "get"
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Servers     []OpenAPIServer             `json:"servers,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *OpenAPISchema `json:"schema,omitempty"`
+}
+
+type OpenAPIRequestBody struct {
+	Description string                      `json:"description,omitempty"`
+	Required    bool                        `json:"required,omitempty"`
+	Content     map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema,omitempty"`
+}
+
+type OpenAPISchema struct {
+	Ref         string                    `json:"$ref,omitempty"`
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Pattern     string                    `json:"pattern,omitempty"`
+	Minimum     *float64                  `json:"minimum,omitempty"`
+	Maximum     *float64                  `json:"maximum,omitempty"`
+	Enum        []string                  `json:"enum,omitempty"`
+	Items       *OpenAPISchema            `json:"items,omitempty"`
+	Properties  map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+}
+
+// routeDoc documents a route on the OpenAPI document
+type routeDoc struct {
+	summary     string
+	description string
+	request     map[string]OpenAPIMediaType
+	responses   map[string]*OpenAPIResponse
+}
+
+func (d routeDoc) clone() routeDoc {
+	nd := routeDoc{summary: d.summary, description: d.description}
+	if d.request != nil {
+		nd.request = make(map[string]OpenAPIMediaType, len(d.request))
+		for t, m := range d.request {
+			nd.request[t] = m
+		}
+	}
+	if d.responses != nil {
+		nd.responses = make(map[string]*OpenAPIResponse, len(d.responses))
+		for s, r := range d.responses {
+			nr := *r
+			nr.Content = make(map[string]OpenAPIMediaType, len(r.Content))
+			for t, m := range r.Content {
+				nr.Content[t] = m
+			}
+			nd.responses[s] = &nr
+		}
+	}
+	return nd
+}
+
+// Summary sets the summary of the route on the OpenAPI document
+func (route *RouteBuilder) Summary(s string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	route.doc.summary = s
+	return route
+}
+
+// Description sets the description of the route on the OpenAPI document
+func (route *RouteBuilder) Description(s string) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	route.doc.description = s
+	return route
+}
+
+// RequestSchema sets the schema of the request body with the media type
+// contentType on the OpenAPI document
+func (route *RouteBuilder) RequestSchema(contentType string, schema *OpenAPISchema) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	t, err := parseMediaType(contentType)
+	if err != nil {
+		route.err = fmt.Errorf("invalid media type %v", contentType)
+		return route
+	}
+	if route.doc.request == nil {
+		route.doc.request = make(map[string]OpenAPIMediaType)
+	}
+	route.doc.request[t] = OpenAPIMediaType{Schema: schema}
+	return route
+}
+
+// ResponseSchema sets the schema of the response body of status with the
+// media type contentType on the OpenAPI document. Empty contentType declares
+// the status without body.
+func (route *RouteBuilder) ResponseSchema(status int, contentType string, schema *OpenAPISchema) *RouteBuilder {
+	if route.err != nil {
+		return route
+	}
+	if http.StatusText(status) == "" {
+		route.err = fmt.Errorf("invalid status %v", status)
+		return route
+	}
+	if route.doc.responses == nil {
+		route.doc.responses = make(map[string]*OpenAPIResponse)
+	}
+	code := strconv.Itoa(status)
+	resp, found := route.doc.responses[code]
+	if !found {
+		resp = &OpenAPIResponse{Description: http.StatusText(status), Content: make(map[string]OpenAPIMediaType)}
+		route.doc.responses[code] = resp
+	}
+	if contentType == "" {
+		return route
+	}
+	t, err := parseMediaType(contentType)
+	if err != nil {
+		route.err = fmt.Errorf("invalid media type %v", contentType)
+		return route
+	}
+	resp.Content[t] = OpenAPIMediaType{Schema: schema}
+	return route
+}
+
+// OpenAPI generates the OpenAPI document of the table in use. The path params
+// are typed following their types and the operations are named by the
+// routes, suffixed by the method when the route has many. The servers of the
+// document are the hosts of the table and the server "/" for all hosts. The
+// operations of the routes of some hosts have their servers, merged when
+// many hosts have the path and method. Only the first route of each path and
+// method on each host is documented.
+func (router *Router) OpenAPI(info OpenAPIInfo) (*OpenAPI, error) {
+	h := router.hostRouter()
+	if h == nil {
+		return nil, fmt.Errorf("router not compiled")
+	}
+
+	doc := &OpenAPI{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]*OpenAPIPathItem),
+	}
+	for _, he := range h.hosts {
+		doc.Servers = append(doc.Servers, openAPIServer(he.host))
+	}
+	doc.Servers = append(doc.Servers, allHostsServer)
+
+	// hosts of the routes documented on each operation
+	hosts := make(map[*OpenAPIOperation]map[string]struct{})
+	err := h.Walk(func(route *Route, host string, segments []string) error {
+		template, params := openAPIPath(segments)
+		item, found := doc.Paths[template]
+		if !found {
+			item = &OpenAPIPathItem{}
+			doc.Paths[template] = item
+		}
+
+		methods := route.Methods()
+		for _, m := range methods {
+			method := strings.ToLower(m)
+			if op, found := (*item)[method]; found {
+				// Operations without servers are already on all hosts
+				if _, found := hosts[op][host]; !found && len(op.Servers) > 0 {
+					hosts[op][host] = struct{}{}
+					op.Servers = append(op.Servers, openAPIServer(host))
+				}
+				continue
+			}
+			op := route.openAPIOperation(params)
+			op.OperationID = route.name
+			if len(methods) > 1 {
+				op.OperationID += "_" + method
+			}
+			if host != "" {
+				op.Servers = []OpenAPIServer{openAPIServer(host)}
+			}
+			hosts[op] = map[string]struct{}{host: {}}
+			(*item)[method] = op
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// OpenAPIHandler serves the OpenAPI document of the table in use as JSON
+func (router *Router) OpenAPIHandler(info OpenAPIInfo) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		doc, err := router.OpenAPI(info)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(doc)
+	})
+}
+
+func (route *Route) openAPIOperation(params []OpenAPIParameter) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Summary:     route.doc.summary,
+		Description: route.doc.description,
+		Parameters:  params,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+
+	doc := route.doc.clone()
+	if len(doc.request) > 0 {
+		op.RequestBody = &OpenAPIRequestBody{Content: doc.request}
+	} else if len(route.consumes) > 0 {
+		op.RequestBody = &OpenAPIRequestBody{Content: make(map[string]OpenAPIMediaType)}
+		for _, t := range route.consumes {
+			op.RequestBody.Content[t] = OpenAPIMediaType{}
+		}
+	}
+
+	if len(doc.responses) > 0 {
+		op.Responses = doc.responses
+	} else {
+		resp := &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+		if len(route.produces) > 0 {
+			resp.Content = make(map[string]OpenAPIMediaType)
+			for _, t := range route.produces {
+				resp.Content[t] = OpenAPIMediaType{}
+			}
+		}
+		op.Responses["200"] = resp
+	}
+	return op
+}
+
+// openAPIPath returns the path template of the segments, like
+// /users/{id}, and its params
+func openAPIPath(segments []string) (string, []OpenAPIParameter) {
+	var params []OpenAPIParameter
+	seen := make(map[string]struct{})
+	add := func(p OpenAPIParameter) {
+		if _, found := seen[p.Name]; !found {
+			seen[p.Name] = struct{}{}
+			params = append(params, p)
+		}
+	}
+
+	builder := strings.Builder{}
+	for _, s := range segments {
+		builder.WriteString("/")
+		seg, err := createSegment(s)
+		if err != nil {
+			builder.WriteString(s)
+			continue
+		}
+
+		switch sn := seg.(type) {
+		case segmentcatchallstring:
+			builder.WriteString("{*}")
+			add(OpenAPIParameter{
+				Name:        "*",
+				In:          "path",
+				Description: "rest of the path",
+				Required:    true,
+				Schema:      &OpenAPISchema{Type: "string"},
+			})
+		case *searchnode:
+			for n := sn; n != nil; n = n.next {
+				if n.kind == searchstatic {
+					builder.WriteString(n.str)
+					continue
+				}
+				builder.WriteString("{" + n.paramname + "}")
+				add(OpenAPIParameter{Name: n.paramname, In: "path", Required: true, Schema: openAPIParamSchema(n.typename)})
+			}
+		default:
+			builder.WriteString(s)
+		}
+	}
+	return builder.String(), params
+}
+
+// openAPIParamSchema returns the schema of the values of a param type, like
+// uint or enum(a|b)
+func openAPIParamSchema(typename string) *OpenAPISchema {
+	switch typename {
+	case "":
+		return &OpenAPISchema{Type: "string"}
+	case "int":
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case "uint":
+		zero := 0.0
+		return &OpenAPISchema{Type: "integer", Format: "int64", Minimum: &zero}
+	case "uuid", "uuidv4":
+		return &OpenAPISchema{Type: "string", Format: "uuid"}
+	case "id":
+		return &OpenAPISchema{Type: "string", Pattern: "^[0-9a-fA-F]+$"}
+	}
+
+	if strings.HasPrefix(typename, "enum(") && strings.HasSuffix(typename, ")") {
+		return &OpenAPISchema{Type: "string", Enum: strings.Split(typename[5:len(typename)-1], "|")}
+	}
+	if !paramTypeExpr.MatchString(typename) {
+		return &OpenAPISchema{Type: "string", Pattern: "^(?:" + typename + ")$"}
+	}
+	// Registered types
+	return &OpenAPISchema{Type: "string", Description: typename}
+}
+
+// allHostsServer is the server of the routes for all hosts
+var allHostsServer = OpenAPIServer{URL: "/", Description: "All hosts"}
+
+// openAPIServer returns the server of a host pattern, allHostsServer for
+// empty host. Wildcard labels are variables named like the URL params: by
+// name, "0", "1"... for "*" and "host*" for {*}.
+func openAPIServer(host string) OpenAPIServer {
+	if host == "" {
+		return allHostsServer
+	}
+	labels := strings.Split(host, ".")
+	server := OpenAPIServer{}
+	wildcard := 0
+	for i, l := range labels {
+		if !isHostWildcard(l) {
+			continue
+		}
+		var name string
+		switch {
+		case l == "*":
+			name = strconv.Itoa(wildcard)
+			wildcard += 1
+		case l == "{*}":
+			name = hostCatchAllParam
+		default:
+			name = strings.SplitN(l[1:len(l)-1], ":", 2)[0]
+		}
+		if server.Variables == nil {
+			server.Variables = make(map[string]OpenAPIServerVariable)
+		}
+		server.Variables[name] = OpenAPIServerVariable{Default: name}
+		labels[i] = "{" + name + "}"
+	}
+	server.URL = "https://" + strings.Join(labels, ".")
+	return server
+}
@@ -0,0 +1,160 @@
+package smux
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newOpenAPIRouter(t *testing.T) *Router {
+	router := NewRouter()
+	router.SetHostnames([]string{"{tenant}.api.com"})
+
+	userSchema := &OpenAPISchema{
+		Type:       "object",
+		Properties: map[string]*OpenAPISchema{"name": {Type: "string"}},
+		Required:   []string{"name"},
+	}
+	builders := []*RouteBuilder{
+		NewRoute().Name("getUser").Path("/users/{id:uint}").Methods("GET").
+			Summary("Get a user").Description("Returns the user id").
+			ResponseSchema(200, "application/json", userSchema).ResponseSchema(404, "", nil),
+		NewRoute().Name("createUser").Path("/users").Methods("POST").Consumes("application/json").
+			RequestSchema("application/json", userSchema),
+		NewRoute().Name("keys").Path("/keys/{k:uuid}/{env:enum(dev|prod)}/{code:[A-Z]{3}}").Methods("GET", "DELETE").Produces("text/plain"),
+		NewRoute().Name("files").Path("/files/v{n:int}/{*}").Methods("GET"),
+		NewRoute().Name("tenant").Host("{tenant}.api.com").Path("/info/{x}").Methods("GET"),
+	}
+	addRoutes(t, router, builders...)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+	return router
+}
+
+func TestOpenAPI(t *testing.T) {
+	doc, err := newOpenAPIRouter(t).OpenAPI(OpenAPIInfo{Title: "Users", Version: "1.0"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Info.Title != "Users" {
+		t.Fatalf("Unexpected document %+v", doc)
+	}
+
+	getUser := (*doc.Paths["/users/{id}"])["get"]
+	if getUser == nil || getUser.OperationID != "getUser" || getUser.Summary != "Get a user" || getUser.Description != "Returns the user id" {
+		t.Fatalf("Unexpected operation %+v", getUser)
+	}
+	id := getUser.Parameters[0]
+	if id.Name != "id" || id.In != "path" || !id.Required || id.Schema.Type != "integer" || *id.Schema.Minimum != 0 {
+		t.Fatalf("Unexpected parameter %+v", id)
+	}
+	if getUser.Responses["200"].Content["application/json"].Schema.Type != "object" || getUser.Responses["404"].Description != "Not Found" {
+		t.Fatalf("Unexpected responses %+v", getUser.Responses)
+	}
+
+	createUser := (*doc.Paths["/users"])["post"]
+	if createUser.RequestBody.Content["application/json"].Schema.Required[0] != "name" || createUser.Responses["200"] == nil {
+		t.Fatalf("Unexpected operation %+v", createUser)
+	}
+
+	keys := doc.Paths["/keys/{k}/{env}/{code}"]
+	if (*keys)["get"].OperationID != "keys_get" || (*keys)["delete"].OperationID != "keys_delete" {
+		t.Fatalf("Unexpected operations %+v", keys)
+	}
+	params := (*keys)["get"].Parameters
+	expected := []*OpenAPISchema{
+		{Type: "string", Format: "uuid"},
+		{Type: "string", Enum: []string{"dev", "prod"}},
+		{Type: "string", Pattern: "^(?:[A-Z]{3})$"},
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(params[i].Schema, expected[i]) {
+			t.Fatalf("Expected schema %+v but was %+v", expected[i], params[i].Schema)
+		}
+	}
+	if _, found := (*keys)["get"].Responses["200"].Content["text/plain"]; !found {
+		t.Fatalf("Unexpected responses %+v", (*keys)["get"].Responses)
+	}
+
+	files := (*doc.Paths["/files/v{n}/{*}"])["get"]
+	if len(files.Parameters) != 2 || files.Parameters[0].Schema.Type != "integer" || files.Parameters[1].Name != "*" {
+		t.Fatalf("Unexpected parameters %+v", files.Parameters)
+	}
+
+	tenant := (*doc.Paths["/info/{x}"])["get"]
+	server := OpenAPIServer{
+		URL:       "https://{tenant}.api.com",
+		Variables: map[string]OpenAPIServerVariable{"tenant": {Default: "tenant"}},
+	}
+	if len(tenant.Servers) != 1 || !reflect.DeepEqual(tenant.Servers[0], server) {
+		t.Fatalf("Unexpected servers %+v", tenant.Servers)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	router := newOpenAPIRouter(t)
+	r, _ := NewRoute().Path("/openapi.json").Methods("GET").Handler(router.OpenAPIHandler(OpenAPIInfo{Title: "Users", Version: "1.0"})).Build()
+	router.AddRoute(r)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rw.Code != 200 || rw.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected answer %v %v", rw.Code, rw.Header())
+	}
+	doc := OpenAPI{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, found := doc.Paths["/openapi.json"]; !found || len(doc.Paths) != 6 {
+		t.Fatalf("Unexpected paths %v", doc.Paths)
+	}
+}
+
+func TestOpenAPIServers(t *testing.T) {
+	router := NewRouter()
+	router.SetHostnames([]string{"a.com", "b.com"})
+	addRoutes(t, router,
+		NewRoute().Name("users").Host("a.com").Path("/users").Methods("GET"),
+		NewRoute().Name("users-b").Host("b.com").Path("/users").Methods("GET"),
+		NewRoute().Name("users-all").Path("/users").Methods("GET"),
+		NewRoute().Name("status").Path("/status").Methods("GET"),
+	)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	doc, err := router.OpenAPI(OpenAPIInfo{Title: "Users", Version: "1.0"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []OpenAPIServer{{URL: "https://a.com"}, {URL: "https://b.com"}, {URL: "/", Description: "All hosts"}}
+	if !reflect.DeepEqual(doc.Servers, expected) {
+		t.Fatalf("Expected servers %+v but was %+v", expected, doc.Servers)
+	}
+
+	users := (*doc.Paths["/users"])["get"]
+	if users.OperationID != "users" || !reflect.DeepEqual(users.Servers, expected) {
+		t.Fatalf("Unexpected operation %+v", users)
+	}
+	if status := (*doc.Paths["/status"])["get"]; status.Servers != nil {
+		t.Fatalf("Unexpected servers %+v", status.Servers)
+	}
+}
+
+func TestOpenAPIErrors(t *testing.T) {
+	builders := []*RouteBuilder{
+		NewRoute().RequestSchema("json", nil),
+		NewRoute().ResponseSchema(999, "", nil),
+		NewRoute().ResponseSchema(200, "json", nil),
+	}
+	for i, rb := range builders {
+		if rb.GetError() == nil {
+			t.Fatalf("Builder %v must have some error", i)
+		}
+	}
+}
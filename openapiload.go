@@ -0,0 +1,471 @@
+package smux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// UnmarshalJSON decodes the operations of a path item. The parameters of the
+// path item are added to its operations and its servers are the servers of
+// the operations without servers. The other fields are ignored.
+func (item *OpenAPIPathItem) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	var params []OpenAPIParameter
+	if raw, found := fields["parameters"]; found {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return err
+		}
+	}
+	var servers []OpenAPIServer
+	if raw, found := fields["servers"]; found {
+		if err := json.Unmarshal(raw, &servers); err != nil {
+			return err
+		}
+	}
+
+	*item = make(OpenAPIPathItem)
+	for _, method := range openAPIMethods {
+		raw, found := fields[method]
+		if !found {
+			continue
+		}
+		op := &OpenAPIOperation{}
+		if err := json.Unmarshal(raw, op); err != nil {
+			return err
+		}
+		// The parameters of the operation override the ones of the path item
+		for _, p := range params {
+			overridden := false
+			for _, q := range op.Parameters {
+				if q.Name == p.Name && q.In == p.In {
+					overridden = true
+					break
+				}
+			}
+			if !overridden {
+				op.Parameters = append(op.Parameters, p)
+			}
+		}
+		if len(op.Servers) == 0 {
+			op.Servers = servers
+		}
+		(*item)[method] = op
+	}
+	return nil
+}
+
+// ParseOpenAPI decodes a JSON OpenAPI 3 document. The fields not modeled by
+// OpenAPI are ignored.
+func ParseOpenAPI(r io.Reader) (*OpenAPI, error) {
+	doc := &OpenAPI{}
+	if err := json.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %v", doc.OpenAPI)
+	}
+	return doc, nil
+}
+
+// OpenAPIBindError lists the operations without handlers and the handlers
+// without operations
+type OpenAPIBindError struct {
+	Unbound []string
+	Unknown []string
+}
+
+func (e *OpenAPIBindError) Error() string {
+	var msgs []string
+	if len(e.Unbound) > 0 {
+		msgs = append(msgs, "operations without handler: "+strings.Join(e.Unbound, ", "))
+	}
+	if len(e.Unknown) > 0 {
+		msgs = append(msgs, "handlers without operation: "+strings.Join(e.Unknown, ", "))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// RouteBuilders returns a builder for each operation of the document, named
+// by its operationId, without handler. The path params are typed by their
+// schemas: integers are int, or uint with minimum 0, strings with format uuid
+// are uuid, enums are enum and patterns are regular expressions. The servers
+// of an operation, or else of its path item, or else of the document, give
+// the hosts of its routes and the base paths in front of its path, with the
+// variables of the base paths replaced by their defaults. Relative servers
+// are for all hosts. The operations with many servers have a route for each
+// one, named like "getUser@a.com/v1", or just by the operationId for the
+// relative server "/". The servers for some hosts with the base path of a
+// relative server have no routes, as the route for all hosts serves them.
+// The operationId is on the metadata "operationId" of the routes. Required
+// request bodies are consumed with their media types.
+func (doc *OpenAPI) RouteBuilders() ([]*RouteBuilder, error) {
+	templates := make([]string, 0, len(doc.Paths))
+	for t := range doc.Paths {
+		templates = append(templates, t)
+	}
+	sort.Strings(templates)
+
+	var builders []*RouteBuilder
+	ids := make(map[string]struct{})
+	for _, template := range templates {
+		item := doc.Paths[template]
+		if item == nil {
+			continue
+		}
+		for _, method := range openAPIMethods {
+			op := (*item)[method]
+			if op == nil {
+				continue
+			}
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("operation %v %v without operationId", strings.ToUpper(method), template)
+			}
+			if _, found := ids[op.OperationID]; found {
+				return nil, fmt.Errorf("operationId %v repeated", op.OperationID)
+			}
+			ids[op.OperationID] = struct{}{}
+
+			servers := op.Servers
+			if len(servers) == 0 {
+				servers = doc.Servers
+			}
+			roots, err := openAPIRoots(servers)
+			if err != nil {
+				return nil, fmt.Errorf("operation %v: %v", op.OperationID, err)
+			}
+			for _, root := range roots {
+				name := op.OperationID
+				if len(roots) > 1 && root.host+root.base != "" {
+					name += "@" + root.host + root.base
+				}
+				rb, err := op.routeBuilder(template, method, name, root)
+				if err != nil {
+					return nil, fmt.Errorf("operation %v: %v", op.OperationID, err)
+				}
+				builders = append(builders, rb)
+			}
+		}
+	}
+	return builders, nil
+}
+
+var openAPITemplateParam = regexp.MustCompile(`\{([^{}/]*)\}`)
+
+// operationIDKey is the metadata key of the operationId of the routes
+const operationIDKey = "operationId"
+
+// openAPIRoot is where a server serves the operations: the host pattern,
+// empty for all hosts, and the base path, empty for the root
+type openAPIRoot struct {
+	host string
+	base string
+}
+
+// openAPIRoots returns the roots of servers, the root path of all hosts when
+// there are no servers. The roots for some hosts with the base path of a root
+// for all hosts are left out, as the latter serves them.
+func openAPIRoots(servers []OpenAPIServer) ([]openAPIRoot, error) {
+	if len(servers) == 0 {
+		return []openAPIRoot{{}}, nil
+	}
+
+	roots := make([]openAPIRoot, 0, len(servers))
+	allhosts := make(map[string]struct{})
+	seen := make(map[openAPIRoot]struct{})
+	for _, server := range servers {
+		host, err := openAPIHost(server.URL)
+		if err != nil {
+			return nil, err
+		}
+		base, err := openAPIBasePath(server)
+		if err != nil {
+			return nil, err
+		}
+		root := openAPIRoot{host, base}
+		if _, found := seen[root]; found {
+			continue
+		}
+		seen[root] = struct{}{}
+		if host == "" {
+			allhosts[base] = struct{}{}
+		}
+		roots = append(roots, root)
+	}
+
+	n := 0
+	for _, root := range roots {
+		if _, found := allhosts[root.base]; found && root.host != "" {
+			continue
+		}
+		roots[n] = root
+		n += 1
+	}
+	return roots[:n], nil
+}
+
+func (op *OpenAPIOperation) routeBuilder(template, method, name string, root openAPIRoot) (*RouteBuilder, error) {
+	schemas := make(map[string]*OpenAPISchema)
+	for _, p := range op.Parameters {
+		if p.In == "path" {
+			schemas[p.Name] = p.Schema
+		}
+	}
+
+	var err error
+	path := openAPITemplateParam.ReplaceAllStringFunc(template, func(s string) string {
+		name := s[1 : len(s)-1]
+		if name == "*" {
+			return s
+		}
+		if !paramTypeName.MatchString(name) {
+			err = fmt.Errorf("invalid path param %v", name)
+			return s
+		}
+		t, terr := openAPIParamType(schemas[name])
+		if terr != nil {
+			err = fmt.Errorf("path param %v: %v", name, terr)
+			return s
+		}
+		if t == "" {
+			return s
+		}
+		return "{" + name + ":" + t + "}"
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "/" && root.base != "" {
+		path = root.base
+	} else {
+		path = root.base + path
+	}
+
+	rb := NewRoute().Name(name).Path(path).Methods(method).Metadata(operationIDKey, op.OperationID).
+		Summary(op.Summary).Description(op.Description)
+	if root.host != "" {
+		rb.Host(root.host)
+	}
+
+	if op.RequestBody != nil {
+		var types []string
+		for t, m := range op.RequestBody.Content {
+			types = append(types, t)
+			rb.RequestSchema(t, m.Schema)
+		}
+		sort.Strings(types)
+		if op.RequestBody.Required && len(types) > 0 {
+			rb.Consumes(types...)
+		}
+	}
+
+	for code, resp := range op.Responses {
+		status, err := strconv.Atoi(code)
+		if err != nil || resp == nil {
+			// Ranges like 2XX and default have no status
+			continue
+		}
+		rb.ResponseSchema(status, "", nil)
+		for t, m := range resp.Content {
+			rb.ResponseSchema(status, t, m.Schema)
+		}
+		if r := rb.doc.responses[code]; r != nil && resp.Description != "" {
+			r.Description = resp.Description
+		}
+	}
+
+	return rb, rb.GetError()
+}
+
+// openAPIParamType returns the param type of the values of schema, empty for
+// any value
+func openAPIParamType(schema *OpenAPISchema) (string, error) {
+	if schema == nil {
+		return "", nil
+	}
+
+	switch {
+	case len(schema.Enum) > 0:
+		for _, v := range schema.Enum {
+			if v == "" || strings.ContainsAny(v, "|(){}/") {
+				return "", fmt.Errorf("invalid enum value %v", v)
+			}
+		}
+		return "enum(" + strings.Join(schema.Enum, "|") + ")", nil
+	case schema.Type == "integer":
+		if schema.Minimum != nil && *schema.Minimum >= 0 {
+			return "uint", nil
+		}
+		return "int", nil
+	case schema.Type == "string" && schema.Format == "uuid":
+		return "uuid", nil
+	case schema.Type == "string" && schema.Pattern != "":
+		expr := strings.TrimSuffix(strings.TrimPrefix(schema.Pattern, "^"), "$")
+		if strings.HasPrefix(expr, "(?:") && strings.HasSuffix(expr, ")") {
+			expr = expr[3 : len(expr)-1]
+		}
+		if _, err := newRegexMatcher(expr); err != nil {
+			return "", err
+		}
+		return expr, nil
+	}
+	return "", nil
+}
+
+// openAPIHost returns the host pattern of a server URL, empty for relative
+// URLs. The variables are wildcards: "*" for the ones named by numbers, {*}
+// for "host*" and {name} for the others.
+func openAPIHost(server string) (string, error) {
+	i := strings.Index(server, "//")
+	if i < 0 {
+		return "", nil
+	}
+	host := server[i+2:]
+	if j := strings.IndexByte(host, '/'); j >= 0 {
+		host = host[:j]
+	}
+	// Removes the port
+	if j := strings.LastIndexByte(host, ':'); j > strings.LastIndexByte(host, '}') {
+		host = host[:j]
+	}
+
+	labels := strings.Split(host, ".")
+	for j, l := range labels {
+		if len(l) < 2 || l[0] != '{' || l[len(l)-1] != '}' {
+			continue
+		}
+		if name := l[1 : len(l)-1]; name == hostCatchAllParam {
+			labels[j] = "{*}"
+		} else if _, err := strconv.Atoi(name); err == nil {
+			labels[j] = "*"
+		}
+	}
+	host = strings.Join(labels, ".")
+	if !verifyHostname(host) {
+		return "", fmt.Errorf("invalid server host %v", host)
+	}
+	return host, nil
+}
+
+var openAPIServerVariable = regexp.MustCompile(`\{([^{}/]*)\}`)
+
+// openAPIBasePath returns the path of the URL of server without the trailing
+// slash, like /v1, with the variables replaced by their defaults
+func openAPIBasePath(server OpenAPIServer) (string, error) {
+	base := server.URL
+	if i := strings.Index(base, "//"); i >= 0 {
+		base = base[i+2:]
+		j := strings.IndexByte(base, '/')
+		if j < 0 {
+			return "", nil
+		}
+		base = base[j:]
+	}
+	if i := strings.IndexAny(base, "?#"); i >= 0 {
+		base = base[:i]
+	}
+
+	var err error
+	base = openAPIServerVariable.ReplaceAllStringFunc(base, func(s string) string {
+		v, found := server.Variables[s[1:len(s)-1]]
+		if !found || v.Default == "" {
+			err = fmt.Errorf("server variable %v without default", s)
+			return s
+		}
+		return v.Default
+	})
+	if err != nil {
+		return "", err
+	}
+
+	base = strings.TrimSuffix(base, "/")
+	if base == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(base, "/") {
+		base = "/" + base
+	}
+	if strings.Contains(base, "{") || !verifyPath(base) {
+		return "", fmt.Errorf("invalid server base path %v", base)
+	}
+	return base, nil
+}
+
+// Routes returns the routes of the operations of the document, with the
+// handlers of reg named by their operationIds. The operations without
+// handler and the handlers without operation are an *OpenAPIBindError.
+func (doc *OpenAPI) Routes(reg *Registry) ([]*Route, error) {
+	builders, err := doc.RouteBuilders()
+	if err != nil {
+		return nil, err
+	}
+
+	bindErr := &OpenAPIBindError{}
+	routes := make([]*Route, 0, len(builders))
+	bound := make(map[string]struct{}, len(builders))
+	unbound := make(map[string]struct{})
+	for _, rb := range builders {
+		id := rb.metadata[operationIDKey]
+		h, err := reg.lookupHandler(id)
+		if err != nil {
+			if _, found := unbound[id]; !found {
+				unbound[id] = struct{}{}
+				bindErr.Unbound = append(bindErr.Unbound, id)
+			}
+			continue
+		}
+		bound[id] = struct{}{}
+		rt, err := rb.Handler(h).Build()
+		if err != nil {
+			return nil, fmt.Errorf("operation %v: %v", rb.name, err)
+		}
+		routes = append(routes, rt)
+	}
+
+	reg.mu.RLock()
+	for name := range reg.handlers {
+		if _, found := bound[name]; !found {
+			bindErr.Unknown = append(bindErr.Unknown, name)
+		}
+	}
+	reg.mu.RUnlock()
+	sort.Strings(bindErr.Unknown)
+
+	if len(bindErr.Unbound) > 0 || len(bindErr.Unknown) > 0 {
+		return nil, bindErr
+	}
+	return routes, nil
+}
+
+// LoadOpenAPI sets the routes of the router to the operations of the
+// document, bound to the handlers of reg, and the hostnames to the hosts of
+// the routes. The routes take effect on Compile.
+func (router *Router) LoadOpenAPI(doc *OpenAPI, reg *Registry) error {
+	routes, err := doc.Routes(reg)
+	if err != nil {
+		return err
+	}
+
+	var hosts []string
+	seen := make(map[string]struct{})
+	for _, rt := range routes {
+		if _, found := seen[rt.host]; rt.host != "" && !found {
+			seen[rt.host] = struct{}{}
+			hosts = append(hosts, rt.host)
+		}
+	}
+	router.SetHostnames(hosts)
+	router.SetRoutes(routes)
+	return nil
+}
@@ -0,0 +1,334 @@
+package smux
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const petstore = `{
+	"openapi": "3.0.3",
+	"info": {"title": "Pets", "version": "1.0"},
+	"paths": {
+		"/pets": {
+			"get": {"operationId": "listPets", "responses": {"200": {"description": "The pets"}}},
+			"post": {
+				"operationId": "createPet",
+				"requestBody": {"required": true, "content": {"application/json": {"schema": {"type": "object"}}}},
+				"responses": {"201": {"description": "Created"}, "default": {"description": "Error"}}
+			}
+		},
+		"/pets/{petId}": {
+			"parameters": [{"name": "petId", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 0}}],
+			"get": {"operationId": "getPet", "summary": "A pet", "responses": {"200": {"description": "The pet"}}}
+		},
+		"/owners/{id}/pets/{kind}": {
+			"get": {
+				"operationId": "ownerPets",
+				"parameters": [
+					{"name": "id", "in": "path", "required": true, "schema": {"type": "string", "format": "uuid"}},
+					{"name": "kind", "in": "path", "required": true, "schema": {"type": "string", "enum": ["cat", "dog"]}}
+				],
+				"servers": [{"url": "https://{tenant}.pets.com/v1", "variables": {"tenant": {"default": "acme"}}}],
+				"responses": {"200": {"description": "The pets"}}
+			}
+		}
+	}
+}`
+
+func newPetRegistry(t *testing.T, ids ...string) *Registry {
+	reg := NewRegistry()
+	for _, id := range ids {
+		id := id
+		err := reg.Handler(id, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx := GetSmuxContext(r.Context())
+			io.WriteString(rw, id+" "+ctx.PathParam("petId")+ctx.PathParam("kind"))
+		}))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return reg
+}
+
+func TestLoadOpenAPI(t *testing.T) {
+	doc, err := ParseOpenAPI(strings.NewReader(petstore))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	builders, err := doc.RouteBuilders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var paths []string
+	for _, rb := range builders {
+		paths = append(paths, rb.host+rb.path)
+	}
+	expected := []string{"{tenant}.pets.com/v1/owners/{id:uuid}/pets/{kind:enum(cat|dog)}", "/pets", "/pets", "/pets/{petId:uint}"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected %v but was %v", expected, paths)
+	}
+
+	router := NewRouter()
+	reg := newPetRegistry(t, "listPets", "createPet", "getPet", "ownerPets")
+	if err := router.LoadOpenAPI(doc, reg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	testCases := []struct {
+		method      string
+		target      string
+		contentType string
+		code        int
+		body        string
+	}{
+		{"GET", "/pets", "", 200, "listPets "},
+		{"POST", "/pets", "application/json", 200, "createPet "},
+		{"POST", "/pets", "text/plain", 415, ""},
+		{"GET", "/pets/12", "", 200, "getPet 12"},
+		{"GET", "/pets/-1", "", 404, ""},
+		{"GET", "http://acme.pets.com/v1/owners/1b4e28ba-2fa1-11d2-883f-00163e1b4e28/pets/cat", "", 200, "ownerPets cat"},
+		{"GET", "http://acme.pets.com/v1/owners/1b4e28ba-2fa1-11d2-883f-00163e1b4e28/pets/bird", "", 404, ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.method+tC.target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(tC.method, tC.target, nil)
+			if tC.contentType != "" {
+				req.Header.Set("Content-Type", tC.contentType)
+			}
+			router.ServeHTTP(rw, req)
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if tC.code == 200 && rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+
+	// The document generated from the routes has the same operations
+	generated, err := router.OpenAPI(doc.Info)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	getPet := (*generated.Paths["/pets/{petId}"])["get"]
+	if getPet.OperationID != "getPet" || getPet.Summary != "A pet" || getPet.Responses["200"].Description != "The pet" {
+		t.Fatalf("Unexpected operation %+v", getPet)
+	}
+}
+
+func TestOpenAPIRoundTrip(t *testing.T) {
+	router := newOpenAPIRouter(t)
+	doc, err := router.OpenAPI(OpenAPIInfo{Title: "Users", Version: "1.0"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	parsed, err := ParseOpenAPI(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	builders, err := parsed.RouteBuilders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	paths := make(map[string]string)
+	for _, rb := range builders {
+		paths[rb.name] = rb.host + rb.path
+	}
+	expected := map[string]string{
+		"getUser":     "/users/{id:uint}",
+		"createUser":  "/users",
+		"keys_get":    "/keys/{k:uuid}/{env:enum(dev|prod)}/{code:[A-Z]{3}}",
+		"keys_delete": "/keys/{k:uuid}/{env:enum(dev|prod)}/{code:[A-Z]{3}}",
+		"files":       "/files/v{n:int}/{*}",
+		"tenant":      "{tenant}.api.com/info/{x}",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected %v but was %v", expected, paths)
+	}
+
+	server := openAPIServer("{*}.*.{region}.cdn.com")
+	if server.URL != "https://{host*}.{0}.{region}.cdn.com" {
+		t.Fatalf("Wrong server %v", server.URL)
+	}
+	if host, err := openAPIHost(server.URL); err != nil || host != "{*}.*.{region}.cdn.com" {
+		t.Fatalf("Wrong host %v %v", host, err)
+	}
+}
+
+func TestOpenAPIServersRoundTrip(t *testing.T) {
+	router := NewRouter()
+	router.SetHostnames([]string{"a.com", "b.com"})
+	// The operation is named by the first route
+	addRoutes(t, router,
+		NewRoute().Name("users").Host("a.com").Path("/users").Methods("GET"),
+		NewRoute().Host("b.com").Path("/users").Methods("GET"),
+		NewRoute().Path("/users").Methods("GET"),
+	)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+	doc, err := router.OpenAPI(OpenAPIInfo{Title: "Users", Version: "1.0"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	builders, err := doc.RouteBuilders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, rb := range builders {
+		names = append(names, rb.name)
+	}
+	// The route for all hosts serves a.com and b.com too
+	expected := []string{"users"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected %v but was %v", expected, names)
+	}
+
+	loaded := NewRouter()
+	reg := NewRegistry()
+	reg.Handler("users", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, GetSmuxContext(r.Context()).Route.Metadata("operationId"))
+	}))
+	if err := loaded.LoadOpenAPI(doc, reg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := loaded.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+	for _, host := range []string{"a.com", "b.com", "c.com"} {
+		rw := httptest.NewRecorder()
+		loaded.ServeHTTP(rw, httptest.NewRequest("GET", "http://"+host+"/users", nil))
+		if rw.Code != 200 || rw.Body.String() != "users" {
+			t.Fatalf("%v: unexpected answer %v %v", host, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func TestOpenAPIServerBasePaths(t *testing.T) {
+	doc, err := ParseOpenAPI(strings.NewReader(`{
+		"openapi": "3.0.3",
+		"info": {"title": "Versions", "version": "1.0"},
+		"servers": [{"url": "https://api.x.com/v1"}],
+		"paths": {
+			"/users/{id}": {
+				"get": {
+					"operationId": "getUser",
+					"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 0}}],
+					"servers": [{"url": "https://a.com/v2/"}],
+					"responses": {"200": {"description": "The user"}}
+				}
+			},
+			"/items": {
+				"servers": [{"url": "/{version}", "variables": {"version": {"default": "v3"}}}],
+				"get": {"operationId": "listItems", "responses": {"200": {"description": "The items"}}}
+			},
+			"/status": {
+				"get": {"operationId": "status", "responses": {"200": {"description": "The status"}}}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	builders, err := doc.RouteBuilders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	paths := make(map[string]string)
+	for _, rb := range builders {
+		paths[rb.name] = rb.host + rb.path
+	}
+	expected := map[string]string{
+		"getUser":   "a.com/v2/users/{id:uint}",
+		"listItems": "/v3/items",
+		"status":    "api.x.com/v1/status",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected %v but was %v", expected, paths)
+	}
+
+	router := NewRouter()
+	if err := router.LoadOpenAPI(doc, newPetRegistry(t, "getUser", "listItems", "status")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+	testCases := []struct {
+		target string
+		code   int
+	}{
+		{"http://a.com/v2/users/7", 200},
+		{"http://a.com/users/7", 404},
+		{"http://a.com/v1/users/7", 404},
+		{"http://b.com/v3/items", 200},
+		{"http://api.x.com/v1/status", 200},
+		{"http://api.x.com/status", 404},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest("GET", tC.target, nil))
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+		})
+	}
+
+	doc.Servers = []OpenAPIServer{{URL: "https://api.x.com/{version}"}}
+	if _, err := doc.RouteBuilders(); err == nil {
+		t.Fatal("Server variables without default must be errors")
+	}
+}
+
+func TestOpenAPIBindErrors(t *testing.T) {
+	doc, err := ParseOpenAPI(strings.NewReader(petstore))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err = doc.Routes(newPetRegistry(t, "listPets", "getPet", "deletePet"))
+	var bindErr *OpenAPIBindError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("Expected an OpenAPIBindError but was %v", err)
+	}
+	if !reflect.DeepEqual(bindErr.Unbound, []string{"ownerPets", "createPet"}) || !reflect.DeepEqual(bindErr.Unknown, []string{"deletePet"}) {
+		t.Fatalf("Unexpected error %v", bindErr)
+	}
+
+	for _, d := range []string{
+		`{"openapi": "2.0"}`,
+		`{"openapi": "3.0.3", "paths": {"/a": {"get": {}}}}`,
+		`{"openapi": "3.0.3", "paths": {"/a/{b-c}": {"get": {"operationId": "a"}}}}`,
+		`{"openapi": "3.0.3", "paths": {"/a": {"get": {"operationId": "a"}}, "/b": {"get": {"operationId": "a"}}}}`,
+		`{"openapi": "3.0.3", "paths": {"/a/{b}": {"get": {"operationId": "a", "parameters": [{"name": "b", "in": "path", "schema": {"type": "string", "pattern": "(a"}}]}}}}`,
+	} {
+		doc, err := ParseOpenAPI(strings.NewReader(d))
+		if err == nil {
+			_, err = doc.RouteBuilders()
+		}
+		if err == nil {
+			t.Fatalf("Document %v must have some error", d)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package smux
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// RouterOptions configures how the router handles paths without routes
+type RouterOptions struct {
+	// RedirectTrailingSlash redirects to the path with or without the
+	// trailing slash, when it has routes. /users/ is redirected to /users.
+	RedirectTrailingSlash bool
+	// RedirectFixedPath redirects to the cleaned path, without repeated
+	// slashes and . or .. elements, when it has routes. /a//b/../c is
+	// redirected to /a/c.
+	RedirectFixedPath bool
+	// CaseInsensitive matches the static parts of the paths ignoring case.
+	// The params keep the case of the request.
+	CaseInsensitive bool
+	// UseRawPath matches the routes on the escaped path of the request, so
+	// an escaped slash like %2F is part of a segment instead of separating
+	// segments. Otherwise the routes are matched on the decoded path.
+	UseRawPath bool
+	// UnescapePathValues decodes the params matched on the escaped path, when
+	// UseRawPath is set. The params matched on the decoded path are never
+	// decoded again.
+	UnescapePathValues bool
+	// RedirectCode is the status of the redirects, 301 or 308. Zero takes 301
+	// for GET and HEAD and 308 for the other methods, which keep their body.
+	RedirectCode int
+}
+
+func (opts RouterOptions) verify() error {
+	switch opts.RedirectCode {
+	case 0, http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf("invalid redirect code %v", opts.RedirectCode)
+}
+
+func (opts RouterOptions) redirectCode(method string) int {
+	if opts.RedirectCode != 0 {
+		return opts.RedirectCode
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
+
+// requestPath returns the path of r the routes are matched on
+func (opts RouterOptions) requestPath(r *http.Request) string {
+	if opts.UseRawPath {
+		return r.URL.EscapedPath()
+	}
+	return r.URL.Path
+}
+
+// unescapePathParams decodes the path params of ctx from mark on, the ones of
+// the router, when they were matched on the escaped path
+func (opts RouterOptions) unescapePathParams(ctx *Context, mark int) {
+	if !opts.UseRawPath || !opts.UnescapePathValues {
+		return
+	}
+	for i := mark; i < len(ctx.pathParams); i++ {
+		if v, err := url.PathUnescape(ctx.pathParams[i].Value); err == nil {
+			ctx.pathParams[i].Value = v
+		}
+	}
+}
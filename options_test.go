@@ -0,0 +1,77 @@
+package smux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRawPath(t *testing.T) {
+	newRouter := func(opts RouterOptions) *Router {
+		router := NewRouter()
+		router.Options = opts
+		params := func(rw http.ResponseWriter, r *http.Request) {
+			ctx := GetSmuxContext(r.Context())
+			io.WriteString(rw, ctx.PathParam("key")+ctx.PathParam("*"))
+		}
+		addRoutes(t, router,
+			NewRoute().Path("/objects/{key}").Methods("GET").HandlerFunc(params),
+			NewRoute().Path("/objects/{key}/meta").Methods("GET").HandlerFunc(params),
+			NewRoute().Path("/files/{*}").Methods("GET").HandlerFunc(params),
+		)
+		if err := router.Compile(); err != nil {
+			t.Fatalf("Error compiling: %v", err)
+		}
+		return router
+	}
+
+	testCases := []struct {
+		name   string
+		opts   RouterOptions
+		target string
+		code   int
+		body   string
+	}{
+		{"decoded", RouterOptions{}, "/objects/a%2Fb", 404, ""},
+		{"decoded", RouterOptions{}, "/objects/a%20b", 200, "a b"},
+		{"decoded", RouterOptions{UnescapePathValues: true}, "/objects/a%2525b", 200, "a%25b"},
+		{"decoded", RouterOptions{}, "/files/a%2Fb", 200, "a/b"},
+		{"raw", RouterOptions{UseRawPath: true}, "/objects/a%2Fb", 200, "a%2Fb"},
+		{"raw", RouterOptions{UseRawPath: true}, "/objects/a%20b", 200, "a%20b"},
+		{"raw", RouterOptions{UseRawPath: true}, "/objects/a%2Fb/meta", 200, "a%2Fb"},
+		{"unescaped", RouterOptions{UseRawPath: true, UnescapePathValues: true}, "/objects/a%2Fb", 200, "a/b"},
+		{"unescaped", RouterOptions{UseRawPath: true, UnescapePathValues: true}, "/objects/a%20b/meta", 200, "a b"},
+		{"unescaped", RouterOptions{UseRawPath: true, UnescapePathValues: true}, "/objects/a%2525b", 200, "a%25b"},
+		{"unescaped", RouterOptions{UseRawPath: true, UnescapePathValues: true}, "/files/x/a%2Fb", 200, "x/a/b"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name+tC.target, func(t *testing.T) {
+			router := newRouter(tC.opts)
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest("GET", tC.target, nil))
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if tC.code == 200 && rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestRawPathRedirect(t *testing.T) {
+	router := NewRouter()
+	router.Options = RouterOptions{UseRawPath: true, RedirectTrailingSlash: true}
+	r, _ := NewRoute().Path("/objects/{key}").Methods("GET").Handler(http.NotFoundHandler()).Build()
+	router.AddRoute(r)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "/objects/a%2Fb/", nil))
+	if rw.Code != 301 || rw.Header().Get("Location") != "/objects/a%2Fb" {
+		t.Fatalf("Expected redirect to /objects/a%%2Fb but was %v %v", rw.Code, rw.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,168 @@
+package smux
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ParamMatcher decides the values accepted by a path param type, like uint on
+// {id:uint}. Values are read byte by byte: Accepts tells if b can be the i-th
+// byte of a value and Complete tells if v, whose bytes were all accepted, is a
+// whole value, v is never empty. The shortest complete value letting the rest
+// of the segment match is taken.
+type ParamMatcher interface {
+	Accepts(b byte, i int) bool
+	Complete(v string) bool
+}
+
+// ParamMatcherFactory builds the matcher of a type with arguments, like
+// enum(dev|prod). It receives the text between the parentheses.
+type ParamMatcherFactory func(args string) (ParamMatcher, error)
+
+var paramTypeName = regexp.MustCompile(`^[a-zA-Z]\w*$`)
+
+// Types are names, with arguments or not. Anything else is a regular expression.
+var paramTypeExpr = regexp.MustCompile(`^[a-zA-Z]\w*(?:\(.*\))?$`)
+
+var paramTypes = struct {
+	sync.RWMutex
+	matchers  map[string]ParamMatcher
+	factories map[string]ParamMatcherFactory
+}{
+	matchers: make(map[string]ParamMatcher),
+	factories: map[string]ParamMatcherFactory{
+		"enum": newEnumMatcher,
+	},
+}
+
+func init() {
+	for name, kind := range searchPathTypesSubstitutions {
+		paramTypes.matchers[name] = kind
+	}
+}
+
+func checkParamTypeName(name string) error {
+	if !paramTypeName.MatchString(name) {
+		return fmt.Errorf("invalid param type name %v", name)
+	}
+	if _, found := paramTypes.matchers[name]; found {
+		return fmt.Errorf("param type %v already registered", name)
+	}
+	if _, found := paramTypes.factories[name]; found {
+		return fmt.Errorf("param type %v already registered", name)
+	}
+	return nil
+}
+
+// RegisterParamType adds the type name, used on paths like {sha:name}.
+// Routes already parsed are not affected.
+func RegisterParamType(name string, matcher ParamMatcher) error {
+	if matcher == nil {
+		return fmt.Errorf("matcher must not be nil")
+	}
+
+	paramTypes.Lock()
+	defer paramTypes.Unlock()
+
+	if err := checkParamTypeName(name); err != nil {
+		return err
+	}
+	paramTypes.matchers[name] = matcher
+	return nil
+}
+
+// RegisterParamTypeFactory adds the type name with arguments, used on paths
+// like {env:name(dev|prod)}. Routes already parsed are not affected.
+func RegisterParamTypeFactory(name string, factory ParamMatcherFactory) error {
+	if factory == nil {
+		return fmt.Errorf("factory must not be nil")
+	}
+
+	paramTypes.Lock()
+	defer paramTypes.Unlock()
+
+	if err := checkParamTypeName(name); err != nil {
+		return err
+	}
+	paramTypes.factories[name] = factory
+	return nil
+}
+
+// lookupParamType returns the matcher of the type t, like uint or enum(a|b)
+func lookupParamType(t string) (ParamMatcher, error) {
+	paramTypes.RLock()
+	defer paramTypes.RUnlock()
+
+	i := strings.IndexByte(t, '(')
+	if i < 0 {
+		matcher, found := paramTypes.matchers[t]
+		if !found {
+			return nil, fmt.Errorf("unknown type")
+		}
+		return matcher, nil
+	}
+
+	if !strings.HasSuffix(t, ")") {
+		return nil, fmt.Errorf("unclosed arguments")
+	}
+	factory, found := paramTypes.factories[t[:i]]
+	if !found {
+		return nil, fmt.Errorf("unknown type")
+	}
+	return factory(t[i+1 : len(t)-1])
+}
+
+type enummatcher []string
+
+// newEnumMatcher accepts the values separated by |, like enum(dev|prod)
+func newEnumMatcher(args string) (ParamMatcher, error) {
+	values := strings.Split(args, "|")
+	for _, v := range values {
+		if v == "" {
+			return nil, fmt.Errorf("empty enum value on %v", args)
+		}
+	}
+	return enummatcher(values), nil
+}
+
+func (e enummatcher) Accepts(b byte, i int) bool {
+	for _, v := range e {
+		if i < len(v) && v[i] == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (e enummatcher) Complete(v string) bool {
+	for _, value := range e {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// regexmatcher accepts the values matching a regular expression, like
+// {code:[A-Z]{3}}
+type regexmatcher struct {
+	r *regexp.Regexp
+}
+
+func newRegexMatcher(expr string) (ParamMatcher, error) {
+	r, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return nil, err
+	}
+	return regexmatcher{r: r}, nil
+}
+
+func (m regexmatcher) Accepts(b byte, i int) bool {
+	return true
+}
+
+func (m regexmatcher) Complete(v string) bool {
+	return m.r.MatchString(v)
+}
@@ -0,0 +1,202 @@
+package smux
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type hexmatcher int
+
+func (h hexmatcher) Accepts(b byte, i int) bool {
+	return i < int(h) && isxdigit(b)
+}
+
+func (h hexmatcher) Complete(v string) bool {
+	return len(v) == int(h)
+}
+
+type slugmatcher struct{}
+
+func (slugmatcher) Accepts(b byte, i int) bool {
+	return (b >= 'a' && b <= 'z') || isdigit(b) || (i > 0 && b == '-')
+}
+
+func (slugmatcher) Complete(v string) bool {
+	return v[len(v)-1] != '-'
+}
+
+func init() {
+	if err := RegisterParamType("hex40", hexmatcher(40)); err != nil {
+		panic(err)
+	}
+	if err := RegisterParamType("slug", slugmatcher{}); err != nil {
+		panic(err)
+	}
+	err := RegisterParamTypeFactory("digits", func(args string) (ParamMatcher, error) {
+		var n int
+		if _, err := fmt.Sscanf(args, "%d", &n); err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid length %v", args)
+		}
+		return hexmatcher(n), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterParamType(t *testing.T) {
+	for _, name := range []string{"hex40", "uint", "enum", "1abc", "a-b"} {
+		if err := RegisterParamType(name, slugmatcher{}); err == nil {
+			t.Fatalf("Type %v must have some error", name)
+		}
+	}
+	if err := RegisterParamType("nilmatcher", nil); err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	tr := trie{}
+	paths := []string{"/commits/{sha:hex40}", "/posts/{slug:slug}.html", "/{env:enum(dev|prod)}/status"}
+	routes := make(map[string]*Route)
+	for _, path := range paths {
+		r, err := NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path(path).Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := tr.Add(r); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		routes[path] = r
+	}
+
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	testCases := []struct {
+		path   string
+		route  string
+		params map[string]string
+	}{
+		{"/commits/" + sha, "/commits/{sha:hex40}", map[string]string{"sha": sha}},
+		{"/commits/" + sha[:39], "", nil},
+		{"/commits/" + sha + "0", "", nil},
+		{"/posts/hello-world.html", "/posts/{slug:slug}.html", map[string]string{"slug": "hello-world"}},
+		{"/posts/Hello.html", "", nil},
+		{"/posts/hello-.html", "", nil},
+		{"/dev/status", "/{env:enum(dev|prod)}/status", map[string]string{"env": "dev"}},
+		{"/prod/status", "/{env:enum(dev|prod)}/status", map[string]string{"env": "prod"}},
+		{"/pro/status", "", nil},
+		{"/staging/status", "", nil},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.path, func(t *testing.T) {
+			ctx := &Context{}
+			ctx.Reset()
+
+			result := tr.Get(tC.path, ctx)
+			if tC.route == "" {
+				if result != nil {
+					t.Fatal("Must not find")
+				}
+				return
+			}
+			if result == nil || result.Methods()["GET"] != routes[tC.route] {
+				t.Fatalf("Must match %v", tC.route)
+			}
+			for k, v := range tC.params {
+				if ctx.PathParam(k) != v {
+					t.Fatalf("Param %v must be %v but was %v", k, v, ctx.PathParam(k))
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterParamTypeFactory(t *testing.T) {
+	if err := RegisterParamTypeFactory("digits", newEnumMatcher); err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	seg, err := newSearchNode("v{n:digits(3)}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !seg.Match("v123", nil) || seg.Match("v12", nil) {
+		t.Fatal("Wrong match of digits(3)")
+	}
+
+	for _, s := range []string{"{n:digits(x)}", "{n:digits}", "{n:enum()}", "{n:enum(a||b)}", "{n:foo(a)}"} {
+		if _, err := newSearchNode(s); err == nil {
+			t.Fatalf("Segment %v must have some error", s)
+		}
+	}
+
+	// Same type, same arguments: same segment
+	s1, _ := newSearchNode("{a:enum(x|y)}")
+	s2, _ := newSearchNode("{b:enum(x|y)}")
+	s3, _ := newSearchNode("{b:enum(x|z)}")
+	if s1.Comparable() != s2.Comparable() || s1.Comparable() == s3.Comparable() {
+		t.Fatal("Wrong comparables")
+	}
+}
+
+func TestRegexParams(t *testing.T) {
+	tr := trie{}
+	paths := []string{"/airports/{code:[A-Z]{3}}", "/airports/{name}", "/api/{ver:v[0-9]+}/{code:[A-Z]{3}}-{n:uint}"}
+	routes := make(map[string]*Route)
+	for _, path := range paths {
+		r, err := NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path(path).Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := tr.Add(r); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		routes[path] = r
+	}
+
+	testCases := []struct {
+		path   string
+		route  string
+		params map[string]string
+	}{
+		{"/airports/POA", "/airports/{code:[A-Z]{3}}", map[string]string{"code": "POA"}},
+		{"/airports/POAA", "/airports/{name}", map[string]string{"name": "POAA"}},
+		{"/airports/poa", "/airports/{name}", map[string]string{"name": "poa"}},
+		{"/api/v12/GRU-3", "/api/{ver:v[0-9]+}/{code:[A-Z]{3}}-{n:uint}", map[string]string{"ver": "v12", "code": "GRU", "n": "3"}},
+		{"/api/v/GRU-3", "", nil},
+		{"/api/v1/GRU-x", "", nil},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.path, func(t *testing.T) {
+			ctx := &Context{}
+			ctx.Reset()
+
+			result := tr.Get(tC.path, ctx)
+			if tC.route == "" {
+				if result != nil {
+					t.Fatal("Must not find")
+				}
+				return
+			}
+			if result == nil || result.Methods()["GET"] != routes[tC.route] {
+				t.Fatalf("Must match %v", tC.route)
+			}
+			for k, v := range tC.params {
+				if ctx.PathParam(k) != v {
+					t.Fatalf("Param %v must be %v but was %v", k, v, ctx.PathParam(k))
+				}
+			}
+		})
+	}
+
+	// Same regular expression on the same place is a conflict
+	r, _ := NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path("/airports/{iata:[A-Z]{3}}").Build()
+	if err := tr.Add(r); err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	for _, path := range []string{"/a/{x:[a-z}", "/a/{x:[a-z]{2}", "/a/{x:}", "/a/{x:abc}", "/a/x}", "/a/{1x}"} {
+		if _, err := NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path(path).Build(); err == nil {
+			t.Fatalf("Path %v must have some error", path)
+		}
+	}
+}
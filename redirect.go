@@ -0,0 +1,85 @@
+package smux
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// cleanPath returns p without repeated slashes and . or .. elements, keeping
+// the trailing slash. Unlike path.Clean, escaped slashes are left alone.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	elems := strings.Split(p, "/")
+	cleaned := make([]string, 0, len(elems))
+	for _, e := range elems {
+		switch e {
+		case "", ".":
+		case "..":
+			if len(cleaned) > 0 {
+				cleaned = cleaned[:len(cleaned)-1]
+			}
+		default:
+			cleaned = append(cleaned, e)
+		}
+	}
+
+	c := "/" + strings.Join(cleaned, "/")
+	if len(cleaned) > 0 && strings.HasSuffix(p, "/") {
+		c += "/"
+	}
+	return c
+}
+
+// redirectPaths returns the alternative forms of routePath to try, following
+// the options
+func (opts RouterOptions) redirectPaths(routePath string) []string {
+	var paths []string
+	base := routePath
+	if opts.RedirectFixedPath {
+		base = cleanPath(routePath)
+		if base != routePath {
+			paths = append(paths, base)
+		}
+	}
+	if opts.RedirectTrailingSlash && base != "/" {
+		if strings.HasSuffix(base, "/") {
+			paths = append(paths, strings.TrimSuffix(base, "/"))
+		} else {
+			paths = append(paths, base+"/")
+		}
+	}
+	return paths
+}
+
+// redirect answers with a redirect to the first alternative form of
+// routePath with routes, keeping the query. It tells if it did.
+func (router *Router) redirect(rw http.ResponseWriter, r *http.Request, hostRouter *HostRouter, hostname, routePath string, ctx *Context) bool {
+	for _, p := range router.Options.redirectPaths(routePath) {
+		// Paths like //host would be taken as another host
+		if strings.HasPrefix(p, "//") {
+			continue
+		}
+		ctx.Reset()
+		ctx.fold = router.Options.CaseInsensitive
+		if hostRouter.Get(hostname, p, ctx) == nil {
+			continue
+		}
+
+		u := url.URL{Path: p, RawQuery: r.URL.RawQuery}
+		if router.Options.UseRawPath {
+			path, err := url.PathUnescape(p)
+			if err != nil {
+				continue
+			}
+			u.Path, u.RawPath = path, p
+		}
+		rw.Header().Set("Location", u.String())
+		rw.WriteHeader(router.Options.redirectCode(r.Method))
+		return true
+	}
+	return false
+}
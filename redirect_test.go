@@ -0,0 +1,138 @@
+package smux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newRedirectRouter(t *testing.T, opts RouterOptions) *Router {
+	router := NewRouter()
+	router.Options = opts
+
+	params := func(rw http.ResponseWriter, r *http.Request) {
+		ctx := GetSmuxContext(r.Context())
+		io.WriteString(rw, ctx.PathParam("id")+ctx.PathParam("n")+ctx.PathParam("*"))
+	}
+	addRoutes(t, router,
+		NewRoute().Path("/users").Methods("GET", "POST").HandlerFunc(params),
+		NewRoute().Path("/users/{id:uint}/").Methods("GET", "POST").HandlerFunc(params),
+		NewRoute().Path("/files/{*}").Methods("GET", "POST").HandlerFunc(params),
+		NewRoute().Path("/Docs/v{n:uint}").Methods("GET", "POST").HandlerFunc(params),
+	)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+	return router
+}
+
+func TestRedirects(t *testing.T) {
+	router := newRedirectRouter(t, RouterOptions{RedirectTrailingSlash: true, RedirectFixedPath: true})
+
+	testCases := []struct {
+		method   string
+		target   string
+		code     int
+		location string
+	}{
+		{"GET", "/users", 200, ""},
+		{"GET", "/users/", 301, "/users"},
+		{"GET", "/users/?page=2", 301, "/users?page=2"},
+		{"POST", "/users/", 308, "/users"},
+		{"GET", "/users/12", 301, "/users/12/"},
+		{"GET", "//users", 301, "/users"},
+		{"GET", "/a/../users/./12", 301, "/users/12/"},
+		{"GET", "/users/12/../13/", 301, "/users/13/"},
+		{"GET", "/users/x", 404, ""},
+		{"GET", "/files/a//b", 200, ""},
+		{"GET", "/docs/v2", 404, ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.method+tC.target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(tC.method, tC.target, nil))
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if location := rw.Header().Get("Location"); location != tC.location {
+				t.Fatalf("Expected location %v but was %v", tC.location, location)
+			}
+		})
+	}
+}
+
+func TestRedirectsDisabled(t *testing.T) {
+	router := newRedirectRouter(t, RouterOptions{})
+	for _, target := range []string{"/users/", "//users", "/users/12"} {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest("GET", target, nil))
+		if rw.Code != 404 {
+			t.Fatalf("Expected 404 on %v but was %v", target, rw.Code)
+		}
+	}
+}
+
+func TestRedirectCode(t *testing.T) {
+	router := newRedirectRouter(t, RouterOptions{RedirectTrailingSlash: true, RedirectCode: http.StatusPermanentRedirect})
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "/users/", nil))
+	if rw.Code != http.StatusPermanentRedirect {
+		t.Fatalf("Expected 308 but was %v", rw.Code)
+	}
+
+	router.Options.RedirectCode = http.StatusFound
+	if err := router.Compile(); err == nil {
+		t.Fatalf("Compile must fail with redirect code 302")
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	router := newRedirectRouter(t, RouterOptions{CaseInsensitive: true, RedirectTrailingSlash: true})
+
+	testCases := []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/USERS", 200, ""},
+		{"/Users/42/", 200, "42"},
+		{"/docs/V3", 200, "3"},
+		{"/DOCS/v3", 200, "3"},
+		{"/Files/A/b", 200, "A/b"},
+		{"/USERS/", 301, ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest("GET", tC.target, nil))
+			if rw.Code != tC.code {
+				t.Fatalf("Expected %v but was %v", tC.code, rw.Code)
+			}
+			if tC.code == 200 && rw.Body.String() != tC.body {
+				t.Fatalf("Expected %v but was %v", tC.body, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	testCases := []struct {
+		path    string
+		cleaned string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"//", "/"},
+		{"/a//b", "/a/b"},
+		{"/a/./b/", "/a/b/"},
+		{"/a/../../b", "/b"},
+		{"/a/b/..", "/a"},
+		{"/a%2Fb/../c", "/c"},
+	}
+	for _, tC := range testCases {
+		if c := cleanPath(tC.path); c != tC.cleaned {
+			t.Fatalf("Expected %v cleaned to %v but was %v", tC.path, tC.cleaned, c)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package smux
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// URL builds the URL of the route named name. params are pairs of param name
+// and value, like "id", "42". The values are validated against the types of
+// the params and escaped. The named labels of the host are filled like the
+// path params, the "*" labels are filled in order by the params "0", "1"...
+// and the {*} label by the param "host*", as "*" is the catch all of the path.
+func (router *Router) URL(name string, params ...string) (*url.URL, error) {
+	if len(params)%2 != 0 {
+		return nil, fmt.Errorf("params must be pairs of name and value")
+	}
+
+	router.mu.Lock()
+	i := router.indexOf(name)
+	var route *Route
+	if i >= 0 {
+		route = router.routes[i]
+	}
+	router.mu.Unlock()
+
+	if route == nil {
+		return nil, fmt.Errorf("route %v not found", name)
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+	used := make(map[string]struct{}, len(values))
+
+	get := func(key string) (string, error) {
+		v, found := values[key]
+		if !found {
+			return "", fmt.Errorf("missing param %v of route %v", key, name)
+		}
+		used[key] = struct{}{}
+		return v, nil
+	}
+
+	host, err := buildHost(route.host, get)
+	if err != nil {
+		return nil, err
+	}
+
+	rawPath, err := buildPath(route.path, get)
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range values {
+		if _, found := used[key]; !found {
+			return nil, fmt.Errorf("unknown param %v of route %v", key, name)
+		}
+	}
+
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &url.URL{Host: host, Path: path, RawPath: rawPath}, nil
+}
+
+// hostCatchAllParam names the value of the {*} label of the host
+const hostCatchAllParam = "host*"
+
+func buildHost(host string, get func(string) (string, error)) (string, error) {
+	if host == "" {
+		return "", nil
+	}
+
+	labels := strings.Split(host, ".")
+	wildcard := 0
+	for i, l := range labels {
+		if !isHostWildcard(l) {
+			continue
+		}
+
+		var v string
+		var err error
+		switch {
+		case l == "*":
+			v, err = get(strconv.Itoa(wildcard))
+			wildcard += 1
+		case l == "{*}":
+			v, err = get(hostCatchAllParam)
+		default:
+			v, err = get(strings.SplitN(l[1:len(l)-1], ":", 2)[0])
+		}
+		if err != nil {
+			return "", err
+		}
+
+		valid := verifyHostname(v) && !strings.Contains(v, "*") && !strings.Contains(v, "{")
+		if valid && l != "{*}" {
+			valid = !strings.Contains(v, ".") && labelsIntersect(l, v)
+		}
+		if !valid {
+			return "", fmt.Errorf("invalid value %v for host label %v", v, l)
+		}
+		labels[i] = v
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func buildPath(path string, get func(string) (string, error)) (string, error) {
+	segs, err := ParsePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	builder := strings.Builder{}
+	for _, seg := range segs {
+		builder.WriteString("/")
+
+		switch s := seg.(type) {
+		case segmentstring:
+			builder.WriteString(string(s))
+
+		case segmentcatchallstring:
+			v, err := get("*")
+			if err != nil {
+				return "", err
+			}
+			parts := strings.Split(v, "/")
+			for i := range parts {
+				parts[i] = url.PathEscape(parts[i])
+			}
+			builder.WriteString(strings.Join(parts, "/"))
+
+		case *searchnode:
+			p, err := s.build(get)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(p)
+
+		default:
+			return "", fmt.Errorf("segment %v can't be built", seg)
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// build returns the escaped segment with the params filled. The values are
+// validated matching the segment with them.
+func (s *searchnode) build(get func(string) (string, error)) (string, error) {
+	var values []string
+	plain := strings.Builder{}
+	escaped := strings.Builder{}
+
+	for sn := s; sn != nil; sn = sn.next {
+		if sn.kind == searchstatic {
+			plain.WriteString(sn.str)
+			escaped.WriteString(sn.str)
+			continue
+		}
+		v, err := get(sn.paramname)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, v)
+		plain.WriteString(v)
+		escaped.WriteString(url.PathEscape(v))
+	}
+
+	var parms []PathParam
+	if !s.Match(plain.String(), &parms) || len(parms) != len(values) {
+		return "", fmt.Errorf("invalid params for segment %v", s.original)
+	}
+	for i := range parms {
+		if parms[i].Value != values[i] {
+			return "", fmt.Errorf("invalid value %v for param %v of segment %v", values[i], parms[i].Key, s.original)
+		}
+	}
+
+	return escaped.String(), nil
+}
@@ -0,0 +1,120 @@
+package smux
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouterURL(t *testing.T) {
+	router := NewRouter()
+
+	paths := map[string]string{
+		"user":     "/users/{id:uint}",
+		"app":      "/apps/App({x:uuidv4})",
+		"distance": "/distance/from-{from}-to-{to}",
+		"static":   "/static/{*}",
+		"home":     "/",
+	}
+	for name, path := range paths {
+		r, err := NewRoute().Name(name).Path(path).Methods("GET").Handler(http.DefaultServeMux).Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		router.AddRoute(r)
+	}
+	r, _ := NewRoute().Name("tenant").Host("*.example.com").Path("/").Methods("GET").Handler(http.DefaultServeMux).Build()
+	router.AddRoute(r)
+
+	testCases := []struct {
+		name   string
+		params []string
+		url    string
+	}{
+		{"user", []string{"id", "42"}, "/users/42"},
+		{"app", []string{"x", "0b0e4f3c-6a7e-4b8f-9c1d-2e3f4a5b6c7d"}, "/apps/App(0b0e4f3c-6a7e-4b8f-9c1d-2e3f4a5b6c7d)"},
+		{"distance", []string{"to", "porto alegre", "from", "canoas"}, "/distance/from-canoas-to-porto%20alegre"},
+		{"static", []string{"*", "css/main file.css"}, "/static/css/main%20file.css"},
+		{"home", nil, "/"},
+		{"tenant", []string{"0", "acme"}, "//acme.example.com/"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			u, err := router.URL(tC.name, tC.params...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if u.String() != tC.url {
+				t.Fatalf("Expected %v but was %v", tC.url, u.String())
+			}
+		})
+	}
+}
+
+func TestRouterURLErrors(t *testing.T) {
+	router := NewRouter()
+
+	r, _ := NewRoute().Name("user").Path("/users/{id:uint}").Methods("GET").Handler(http.DefaultServeMux).Build()
+	router.AddRoute(r)
+	r, _ = NewRoute().Name("app").Path("/apps/{x:uuidv4}").Methods("GET").Handler(http.DefaultServeMux).Build()
+	router.AddRoute(r)
+
+	testCases := []struct {
+		desc   string
+		name   string
+		params []string
+	}{
+		{"unknown route", "foo", nil},
+		{"odd params", "user", []string{"id"}},
+		{"missing param", "user", nil},
+		{"unknown param", "user", []string{"id", "1", "foo", "bar"}},
+		{"invalid uint", "user", []string{"id", "-1"}},
+		{"invalid uuidv4", "app", []string{"x", "5d8f0b2e-1c3a-11eb-adc1-0242ac120002"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := router.URL(tC.name, tC.params...)
+			if err == nil {
+				t.Fatal("Must have some error")
+			}
+			t.Logf("Error: %v", err)
+		})
+	}
+}
+
+func TestRouterURLHostParams(t *testing.T) {
+	router := NewRouter()
+
+	r, _ := NewRoute().Name("tenant").Host("{*}.{tenant}.example.com").Path("/users/{id:uint}").Methods("GET").Handler(http.DefaultServeMux).Build()
+	router.AddRoute(r)
+	r, _ = NewRoute().Name("typed").Host("{id:uint}.example.com").Path("/").Methods("GET").Handler(http.DefaultServeMux).Build()
+	router.AddRoute(r)
+	r, _ = NewRoute().Name("static").Host("{*}.example.com").Path("/static/{*}").Methods("GET").Handler(http.DefaultServeMux).Build()
+	router.AddRoute(r)
+
+	u, err := router.URL("tenant", "host*", "eu.api", "tenant", "acme", "id", "7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if u.String() != "//eu.api.acme.example.com/users/7" {
+		t.Fatalf("Wrong url %v", u)
+	}
+
+	if _, err := router.URL("tenant", "host*", "eu.api", "tenant", "a.b", "id", "7"); err == nil {
+		t.Fatal("Must have some error")
+	}
+	if _, err := router.URL("typed", "id", "foo"); err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	// The catch alls of the host and of the path are different params
+	if _, err := router.URL("static", "*", "eu"); err == nil {
+		t.Fatal("Must have some error")
+	}
+	u, err = router.URL("static", "host*", "eu", "*", "css/main.css")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if u.String() != "//eu.example.com/static/css/main.css" {
+		t.Fatalf("Wrong url %v", u)
+	}
+}
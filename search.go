@@ -16,16 +16,17 @@ const (
 	searchuuid
 	searchuuidv4
 	searchid
+	// Registered with RegisterParamType
+	searchcustom
 )
 
 type searchnode struct {
 	kind      searchkind
 	str       string
 	paramname string
+	typename  string
+	matcher   ParamMatcher
 	next      *searchnode
-	idx       int
-	all       string
-	final     bool
 	compare   string
 	original  string
 	numvars   int
@@ -34,7 +35,11 @@ type searchnode struct {
 var _ segment = &searchnode{}
 
 func (s *searchnode) Match(p string, parms *[]PathParam) bool {
-	return s.search(p, parms)
+	return s.search(p, parms, false)
+}
+
+func (s *searchnode) MatchFold(p string, parms *[]PathParam) bool {
+	return s.search(p, parms, true)
 }
 
 func (searchnode) CatchAll() bool {
@@ -53,6 +58,18 @@ func (s searchnode) NumVars() int {
 	return s.numvars
 }
 
+func (s searchnode) Priority() int {
+	if s.next == nil {
+		switch s.kind {
+		case searchstatic:
+			return prioritystatic
+		case searchany:
+			return priorityany
+		}
+	}
+	return prioritytyped
+}
+
 var searchPathTypesSubstitutions = map[string]searchkind{
 	"int":    searchsignednumber,
 	"uint":   searchnumber,
@@ -61,33 +78,30 @@ var searchPathTypesSubstitutions = map[string]searchkind{
 	"id":     searchid,
 }
 
-func searchPathSubstitute(s string) (string, searchkind, error) {
-	splt := strings.Split(s, ":")
-	fieldName := splt[0]
-	if len(fieldName) > 0 && fieldName[0] == '{' {
-		fieldName = fieldName[1:]
+// searchPathSubstitute returns the param node of the bracket s
+func searchPathSubstitute(s string) (*searchnode, error) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	splt := strings.SplitN(inner, ":", 2)
+	if len(splt) == 1 {
+		return &searchnode{kind: searchany, paramname: splt[0], matcher: searchany}, nil
 	}
 
-	if len(splt) == 1 {
-		l := len(fieldName)
-		if l > 0 && fieldName[l-1] == '}' {
-			fieldName = fieldName[:l-1]
-		}
-		return fieldName, searchany, nil
-	} else if len(splt) == 2 {
-		t := splt[1]
-		if len(t) > 0 {
-			t = t[:len(t)-1]
-		}
-		change, f := searchPathTypesSubstitutions[t]
-		if !f {
-			return "", 0, fmt.Errorf("invalid type %v on bracket %v", t, s)
-		} else {
-			return fieldName, change, nil
-		}
+	t := splt[1]
+	var matcher ParamMatcher
+	var err error
+	if paramTypeExpr.MatchString(t) {
+		matcher, err = lookupParamType(t)
 	} else {
-		return "", 0, nil
+		matcher, err = newRegexMatcher(t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid type %v on bracket %v: %v", t, s, err)
 	}
+	kind := searchcustom
+	if k, ok := matcher.(searchkind); ok {
+		kind = k
+	}
+	return &searchnode{kind: kind, paramname: splt[0], typename: t, matcher: matcher}, nil
 }
 
 func newSearchNode(r string) (segment, error) {
@@ -97,13 +111,16 @@ func newSearchNode(r string) (segment, error) {
 
 	// This block will remove brackets and test if the rest is valid url path
 	// Replace bracket things with nothing
-	r1 := bracketSimplistic.ReplaceAllString(r, "")
+	r1, err := removeBrackets(r)
+	if err != nil {
+		return nil, err
+	}
 	u, err := url.Parse(r1)
 	if err != nil || u.EscapedPath() != r1 {
 		return nil, fmt.Errorf("invalid url path %v", r)
 	}
 
-	findings := bracketSimplistic.FindAllStringIndex(r, -1)
+	findings, _ := findBrackets(r)
 
 	sn := searchnode{}
 	snp := &sn
@@ -123,13 +140,13 @@ func newSearchNode(r string) (segment, error) {
 				snp = snp.next
 				sn.compare += r[init:fi]
 			}
-			fname, sub, err := searchPathSubstitute(r[fi:fe])
+			param, err := searchPathSubstitute(r[fi:fe])
 			if err != nil {
 				return nil, err
 			}
-			snp.next = &searchnode{kind: sub, paramname: fname}
+			snp.next = param
 			snp = snp.next
-			sn.compare += fmt.Sprintf("{%v}", sub)
+			sn.compare += "{" + param.typename + "}"
 			init = fe
 		}
 		if init < len(r) {
@@ -153,213 +170,117 @@ func newSearchNode(r string) (segment, error) {
 	return sn.next, nil
 }
 
-func (s *searchnode) accepts(all string) int {
-	if s.all == "" {
-		s.all = all
-	}
-
-	switch s.kind {
-	case searchstatic:
-		if s.final {
-			return 0
-		}
-
-		l := len(s.str)
-		if len(all) < l {
-			return 0
-		}
-
-		if all[:l] == s.str {
-			s.final = true
-			s.idx = l
-			return l
-		}
-		return 0
-
+// Accepts tells if b can be the i-th byte of a value of kind k
+func (k searchkind) Accepts(b byte, i int) bool {
+	switch k {
 	case searchany:
-		if s.next == nil {
-			s.idx = len(s.all)
-			s.final = true
-			return s.idx
-		}
-		s.idx += 1
-		s.final = true
-		return 1
-
+		return true
 	case searchnumber:
-		if s.next == nil {
-			for _, b := range s.all {
-				if b < '0' || b > '9' {
-					return 0
-				}
-			}
-			s.idx = len(s.all)
-			s.final = true
-			return s.idx
-		}
-		if all[0] < '0' || all[0] > '9' {
-			return 0
-		}
-		s.idx += 1
-		s.final = true
-		return 1
-
+		return isdigit(b)
 	case searchsignednumber:
-		if s.next == nil {
-			for i, b := range s.all {
-				if b < '0' || b > '9' {
-					if i == 0 && b != '-' {
-						return 0
-					}
-				}
-			}
-			s.idx = len(s.all)
-			s.final = true
-			return s.idx
-		}
-		if all[0] < '0' || all[0] > '9' {
-			if s.idx == 0 && all[0] != '-' {
-				return 0
-			}
-		}
-		s.idx += 1
-		s.final = true
-		return 1
-
+		return isdigit(b) || (i == 0 && b == '-')
+	case searchid:
+		return isxdigit(b)
 	case searchuuid:
-		if s.final {
-			return 0
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			return b == '-'
 		}
-
-		if len(all) < 36 {
-			return 0
-		}
-
-		if all[8] != '-' || all[13] != '-' || all[18] != '-' || all[23] != '-' {
-			return 0
-		}
-
-		for i, b := range all[:36] {
-			if i == 8 || i == 13 || i == 18 || i == 23 {
-				continue
-			}
-
-			if (b < '0' || b > '9') && (b < 'a' || b > 'f') && (b < 'A' || b > 'F') {
-				return 0
-			}
-		}
-		s.idx = 36
-		s.final = true
-		return s.idx
-
+		return i < 36 && isxdigit(b)
 	case searchuuidv4:
-		if s.final {
-			return 0
-		}
-
-		if len(all) < 36 {
-			return 0
-		}
-
-		b := all[14]
-		if b != '4' {
-			return 0
+		switch i {
+		case 8, 13, 18, 23:
+			return b == '-'
+		case 14:
+			return b == '4'
+		case 19:
+			return b == '8' || b == '9' || b == 'a' || b == 'b' || b == 'A' || b == 'B'
 		}
+		return i < 36 && isxdigit(b)
+	}
+	return false
+}
 
-		b = all[19]
-		if (b < '8' || b > '9') && (b < 'a' && b > 'b') && (b < 'A' && b > 'B') {
-			return 0
-		}
-
-		if all[8] != '-' || all[13] != '-' || all[18] != '-' || all[23] != '-' {
-			return 0
-		}
-
-		for i, b := range all[:36] {
-			if i == 14 || i == 19 || i == 8 || i == 13 || i == 18 || i == 23 {
-				continue
-			}
-			if (b < '0' || b > '9') && (b < 'a' || b > 'f') && (b < 'A' || b > 'F') {
-				return 0
-			}
-		}
-		s.idx = 36
-		s.final = true
-		return s.idx
-
-	case searchid:
-		if s.next == nil {
-			for _, b := range s.all {
-				if (b < '0' || b > '9') && (b < 'a' || b > 'f') && (b < 'A' || b > 'F') {
-					return 0
-				}
-			}
-			s.idx = len(s.all)
-			s.final = true
-			return s.idx
-		}
-		b := all[0]
-		if (b < '0' || b > '9') && (b < 'a' || b > 'f') && (b < 'A' || b > 'F') {
-			return 0
-		}
-		s.idx += 1
-		s.final = true
-		return 1
+// Complete tells if v, whose bytes were all accepted, is a whole value of kind k
+func (k searchkind) Complete(v string) bool {
+	switch k {
+	case searchuuid, searchuuidv4:
+		return len(v) == 36
+	case searchsignednumber:
+		return len(v) > 0 && v != "-"
 	}
+	return len(v) > 0
+}
 
-	return 0
+func isdigit(b byte) bool {
+	return b >= '0' && b <= '9'
 }
 
-func (s *searchnode) reset() {
-	s.final = false
-	s.idx = 0
-	s.all = ""
+func isxdigit(b byte) bool {
+	return isdigit(b) || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
 }
 
-func (s searchnode) saveparam(parms *[]PathParam) {
-	if s.kind != searchstatic {
-		if parms != nil {
-			*parms = append(*parms, PathParam{Key: s.paramname, Value: s.all[:s.idx]})
-		}
+func (s searchnode) saveparam(parms *[]PathParam, value string) {
+	if parms != nil {
+		*parms = append(*parms, PathParam{Key: s.paramname, Value: value})
 	}
 }
 
-func (s *searchnode) search(input string, parms *[]PathParam) bool {
-	nx := s.next
-
-	if s.next == nil && s.kind == searchany && len(input) > 0 {
-		if parms != nil {
-			*parms = append(*parms, PathParam{Key: s.paramname, Value: input})
+// search matches the whole input with the chain of nodes starting on s.
+// Params are matched lazily, backtracking when the rest of the chain fails.
+// The state of the search lives on the stack, so the nodes are never modified
+// and can be used by concurrent requests. fold ignores the case of the static
+// nodes.
+func (s *searchnode) search(input string, parms *[]PathParam, fold bool) bool {
+	if s.kind == searchstatic {
+		if len(input) < len(s.str) {
+			return false
 		}
-		return true
-	}
-
-	i := 0
-	for {
-		if i == len(input) {
-			if nx == nil && s.final {
-				s.saveparam(parms)
-				s.reset()
-				return true
-			} else {
-				s.reset()
+		if fold {
+			if !strings.EqualFold(input[:len(s.str)], s.str) {
 				return false
 			}
+		} else if input[:len(s.str)] != s.str {
+			return false
 		}
+		if s.next == nil {
+			return len(input) == len(s.str)
+		}
+		return s.next.search(input[len(s.str):], parms, fold)
+	}
 
-		if s.final && nx != nil {
-			acc := nx.accepts(input[i:])
-			if acc > 0 && nx.search(input[i+acc:], parms) {
-				s.saveparam(parms)
-				s.reset()
-				return true
+	// The last node takes all the rest of input
+	if s.next == nil {
+		if len(input) == 0 {
+			return false
+		}
+		for i := 0; i < len(input); i++ {
+			if !s.matcher.Accepts(input[i], i) {
+				return false
 			}
 		}
-		if acc := s.accepts(input[i:]); acc > 0 {
-			i += acc
+		if !s.matcher.Complete(input) {
+			return false
+		}
+		s.saveparam(parms, input)
+		return true
+	}
+
+	mark := 0
+	if parms != nil {
+		mark = len(*parms)
+	}
+	for i := 0; i < len(input) && s.matcher.Accepts(input[i], i); i++ {
+		value := input[:i+1]
+		if !s.matcher.Complete(value) {
 			continue
 		}
-		s.reset()
-		return false
+		s.saveparam(parms, value)
+		if s.next.search(input[i+1:], parms, fold) {
+			return true
+		}
+		if parms != nil {
+			*parms = (*parms)[:mark]
+		}
 	}
+	return false
 }
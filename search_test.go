@@ -0,0 +1,82 @@
+package smux
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSearchNodeMatch(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		input   string
+		match   bool
+		params  []PathParam
+	}{
+		{"{name}.{ext}", "report.pdf", true, []PathParam{{"name", "report"}, {"ext", "pdf"}}},
+		{"{name}.{ext}", "report.tar.gz", true, []PathParam{{"name", "report"}, {"ext", "tar.gz"}}},
+		{"{name}.{ext}", "report", false, nil},
+		{"{a}-{b}", "-b", false, nil},
+		{"from-{from}-to-{to}", "from-canoas-to-poa", true, []PathParam{{"from", "canoas"}, {"to", "poa"}}},
+		{"wait-{t:uint}", "wait-200", true, []PathParam{{"t", "200"}}},
+		{"wait-{t:uint}", "wait-2a", false, nil},
+		{"wait-{t:int}", "wait--20", true, []PathParam{{"t", "-20"}}},
+		{"wait-{t:int}", "wait--", false, nil},
+		{"{a:uint}{b:id}", "12ab", true, []PathParam{{"a", "1"}, {"b", "2ab"}}},
+		{"App({id:uuid})", "App(5d8f0b2e-1c3a-11eb-adc1-0242ac120002)", true, []PathParam{{"id", "5d8f0b2e-1c3a-11eb-adc1-0242ac120002"}}},
+		{"App({id:uuidv4})", "App(5d8f0b2e-1c3a-11eb-adc1-0242ac120002)", false, nil},
+		{"App({id:uuidv4})", "App(0b0e4f3c-6a7e-4b8f-9c1d-2e3f4a5b6c7d)", true, []PathParam{{"id", "0b0e4f3c-6a7e-4b8f-9c1d-2e3f4a5b6c7d"}}},
+		{"App({id:uuidv4})", "App(0b0e4f3c-6a7e-4b8f-cc1d-2e3f4a5b6c7d)", false, nil},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.pattern+" "+tC.input, func(t *testing.T) {
+			seg, err := newSearchNode(tC.pattern)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var parms []PathParam
+			if seg.Match(tC.input, &parms) != tC.match {
+				t.Fatalf("Match must be %v", tC.match)
+			}
+			if !tC.match {
+				if len(parms) > 0 {
+					t.Fatalf("Params must be empty: %v", parms)
+				}
+				return
+			}
+			if fmt.Sprint(parms) != fmt.Sprint(tC.params) {
+				t.Fatalf("Expected params %v but were %v", tC.params, parms)
+			}
+		})
+	}
+}
+
+func TestSearchNodeConcurrent(t *testing.T) {
+	seg, err := newSearchNode("{name}.{ext}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				name := fmt.Sprintf("file%d-%d", g, i)
+				ext := fmt.Sprintf("e%d", g)
+				var parms []PathParam
+				if !seg.Match(name+"."+ext, &parms) {
+					t.Errorf("Must match %v.%v", name, ext)
+					return
+				}
+				if len(parms) != 2 || parms[0].Value != name || parms[1].Value != ext {
+					t.Errorf("Wrong params for %v.%v: %v", name, ext, parms)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
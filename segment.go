@@ -9,12 +9,23 @@ import (
 
 type segment interface {
 	Match(p string, parms *[]PathParam) bool
+	// MatchFold is like Match, ignoring the case of the static text
+	MatchFold(p string, parms *[]PathParam) bool
 	CatchAll() bool
 	String() string
 	Comparable() string
 	NumVars() int
+	// Priority orders the segments of a node, the lower is tried first
+	Priority() int
 }
 
+const (
+	prioritystatic = iota
+	prioritytyped
+	priorityany
+	prioritycatchall
+)
+
 type segmentstring string
 
 func (s segmentstring) String() string {
@@ -29,6 +40,10 @@ func (s segmentstring) Match(p string, parms *[]PathParam) bool {
 	return p == string(s)
 }
 
+func (s segmentstring) MatchFold(p string, parms *[]PathParam) bool {
+	return strings.EqualFold(p, string(s))
+}
+
 func (s segmentstring) CatchAll() bool {
 	return false
 }
@@ -37,6 +52,10 @@ func (s segmentstring) NumVars() int {
 	return 0
 }
 
+func (s segmentstring) Priority() int {
+	return prioritystatic
+}
+
 type segmentcatchallstring struct{}
 
 func (s segmentcatchallstring) String() string {
@@ -51,6 +70,10 @@ func (s segmentcatchallstring) Match(p string, parms *[]PathParam) bool {
 	return true
 }
 
+func (s segmentcatchallstring) MatchFold(p string, parms *[]PathParam) bool {
+	return true
+}
+
 func (s segmentcatchallstring) CatchAll() bool {
 	return true
 }
@@ -59,13 +82,92 @@ func (s segmentcatchallstring) NumVars() int {
 	return 1
 }
 
+func (s segmentcatchallstring) Priority() int {
+	return prioritycatchall
+}
+
 type segmentregex struct {
 	r        *regexp.Regexp
 	original string
 	fields   []string
 }
 
-var bracketSimplistic = regexp.MustCompile(`\{[a-zA-Z]\w*(?::[a-zA-Z]\w*)?\}`)
+// Brackets like {name}, {name:type} or {name:type(args)}
+const bracketPattern = `\{[a-zA-Z]\w*(?::[a-zA-Z]\w*(?:\([^{}()/]*\))?)?\}`
+
+var bracketSimplistic = regexp.MustCompile(bracketPattern)
+
+// findBrackets returns the index pairs of the brackets of s, like
+// bracketSimplistic.FindAllStringIndex, also accepting regular expressions
+// as type, which may have braces, like {code:[A-Z]{3}}
+func findBrackets(s string) ([][]int, error) {
+	var findings [][]int
+	for i := 0; i < len(s); i++ {
+		if s[i] == '}' {
+			return nil, fmt.Errorf("unopened bracket on %v", s)
+		}
+		if s[i] != '{' {
+			continue
+		}
+
+		j := i + 1
+		for j < len(s) && (s[j] == '_' || isalpha(s[j]) || (j > i+1 && isdigit(s[j]))) {
+			j += 1
+		}
+		if j == i+1 || j == len(s) || (s[j] != '}' && s[j] != ':') {
+			return nil, fmt.Errorf("invalid bracket on %v", s)
+		}
+
+		if s[j] == ':' {
+			j += 1
+			begin := j
+			depth := 1
+			for ; j < len(s); j++ {
+				if s[j] == '\\' {
+					j += 1
+				} else if s[j] == '{' {
+					depth += 1
+				} else if s[j] == '}' {
+					depth -= 1
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unclosed bracket on %v", s)
+			}
+			if j == begin {
+				return nil, fmt.Errorf("empty type on %v", s)
+			}
+		}
+
+		findings = append(findings, []int{i, j + 1})
+		i = j
+	}
+	return findings, nil
+}
+
+// removeBrackets returns s without its brackets
+func removeBrackets(s string) (string, error) {
+	findings, err := findBrackets(s)
+	if err != nil {
+		return "", err
+	}
+	builder := strings.Builder{}
+	init := 0
+	for _, f := range findings {
+		builder.WriteString(s[init:f[0]])
+		init = f[1]
+	}
+	builder.WriteString(s[init:])
+	return builder.String(), nil
+}
+
+func isalpha(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
 var pathTypesSubstitutions = map[string]string{
 	"int":    `-?\d+`,
 	"uint":   `\d+`,
@@ -184,6 +286,11 @@ func (r segmentregex) Match(p string, parms *[]PathParam) bool {
 	return true
 }
 
+// MatchFold is Match, as segmentregex isn't used on the tries
+func (r segmentregex) MatchFold(p string, parms *[]PathParam) bool {
+	return r.Match(p, parms)
+}
+
 func (s segmentregex) NumVars() int {
 	return strings.Count(s.original, "{")
 }
@@ -192,6 +299,10 @@ func (segmentregex) CatchAll() bool {
 	return false
 }
 
+func (segmentregex) Priority() int {
+	return prioritytyped
+}
+
 type segmentany struct {
 	comp      string
 	original  string
@@ -225,6 +336,10 @@ func (s segmentany) Match(p string, parms *[]PathParam) bool {
 	return true
 }
 
+func (s segmentany) MatchFold(p string, parms *[]PathParam) bool {
+	return s.Match(p, parms)
+}
+
 func (s segmentany) NumVars() int {
 	return 1
 }
@@ -233,6 +348,10 @@ func (s segmentany) CatchAll() bool {
 	return false
 }
 
+func (s segmentany) Priority() int {
+	return priorityany
+}
+
 func createSegment(s string) (segment, error) {
 	if s == "{*}" {
 		return segmentcatchallstring{}, nil
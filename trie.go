@@ -3,6 +3,7 @@ package smux
 import (
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 )
 
@@ -11,7 +12,10 @@ func verifyPath(path string) bool {
 	if strings.HasSuffix(r1, "{*}") {
 		r1 = strings.TrimRight(path, "{*}")
 	}
-	r1 = bracketSimplistic.ReplaceAllString(r1, "")
+	r1, err := removeBrackets(r1)
+	if err != nil {
+		return false
+	}
 	u, err := url.ParseRequestURI(r1)
 	if err != nil {
 		return false
@@ -49,13 +53,34 @@ func ParsePath(path string) ([]segment, error) {
 }
 
 type node struct {
-	seg     segment
-	methods map[string]*Route
+	seg segment
+	// methods has the candidate routes of each method, in the order they are
+	// tried. The route without matchers, if any, is the last one.
+	methods map[string][]*Route
 	nodes   []*node
 }
 
+// Methods returns the first candidate route of each method
 func (n node) Methods() map[string]*Route {
-	return n.methods
+	ms := make(map[string]*Route, len(n.methods))
+	for m, rts := range n.methods {
+		ms[m] = rts[0]
+	}
+	return ms
+}
+
+func (n node) Routes(method string) []*Route {
+	return n.methods[method]
+}
+
+// AllowedMethods returns the sorted methods with a route
+func (n node) AllowedMethods() []string {
+	ms := make([]string, 0, len(n.methods))
+	for m := range n.methods {
+		ms = append(ms, m)
+	}
+	sort.Strings(ms)
+	return ms
 }
 
 type trie struct {
@@ -114,12 +139,16 @@ func (t *trie) Add(r *Route) error {
 		// 	n = nn
 		// }
 
-		// Not found - Add new node
+		// Not found - Add new node, after the ones with the same priority
 		if n == inode {
-			n = &node{seg: s, methods: make(map[string]*Route)}
-			// inode.nodes[s.Comparable()] = n
-			inode.nodes = append(inode.nodes, n)
-			// n = &inode.nodes[len(inode.nodes)-1]
+			n = &node{seg: s, methods: make(map[string][]*Route)}
+			j := len(inode.nodes)
+			for j > 0 && inode.nodes[j-1].seg.Priority() > s.Priority() {
+				j -= 1
+			}
+			inode.nodes = append(inode.nodes, nil)
+			copy(inode.nodes[j+1:], inode.nodes[j:])
+			inode.nodes[j] = n
 		}
 
 		//backtrack = append(backtrack, n)
@@ -128,15 +157,17 @@ func (t *trie) Add(r *Route) error {
 
 		// Must set value
 		if i == l-1 {
-			// Must not add route to already set method
-			for m := range n.methods {
-				if _, found := r.methods[m]; found {
+			// Must not add route without matchers to already set method,
+			// unless the others have matchers
+			for m := range r.methods {
+				rts := n.methods[m]
+				if len(rts) > 0 && !r.hasMatchers() && !rts[len(rts)-1].hasMatchers() {
 					return fmt.Errorf("Path segment already handled")
 				}
 			}
 
 			for m := range r.methods {
-				n.methods[m] = r
+				n.methods[m] = insertCandidate(n.methods[m], r)
 			}
 		}
 	}
@@ -152,66 +183,209 @@ func (t *trie) Add(r *Route) error {
 	return nil
 }
 
-// The fast case (no recursion)
-func (t trie) Get(path string, ctx *Context) MatchResult {
-	n := &t.node
+// insertCandidate adds r to the candidates of a method, before the route
+// without matchers
+func insertCandidate(rts []*Route, r *Route) []*Route {
+	j := len(rts)
+	if r.hasMatchers() && j > 0 && !rts[j-1].hasMatchers() {
+		j -= 1
+	}
+	rts = append(rts, nil)
+	copy(rts[j+1:], rts[j:])
+	rts[j] = r
+	return rts
+}
 
-	originalpath := path
-	ior := 0
+// Remove removes r from the trie, pruning the nodes left without routes
+func (t *trie) Remove(r *Route) error {
+	if r == nil {
+		return nil
+	}
 
-	if len(path) > 0 && path[0] == '/' {
-		path = path[1:]
-		ior += 1
+	path, err := ParsePath(r.path)
+	if err != nil {
+		return err
 	}
 
-	var match bool
-	// var parms []PathParam
-	ctx.pathParams = make([]PathParam, 0, t.maxparams)
-	parms := &ctx.pathParams
+	n := &t.node
+	nodes := make([]*node, 1, len(path)+1)
+	nodes[0] = n
+	for _, s := range path {
+		inode := n
+		for j := range inode.nodes {
+			if inode.nodes[j].seg.Comparable() == s.Comparable() {
+				n = inode.nodes[j]
+				break
+			}
+		}
+		if n == inode {
+			return fmt.Errorf("route not found")
+		}
+		nodes = append(nodes, n)
+	}
 
-	for {
-		i := strings.IndexRune(path, '/')
-		ii := i
-		if i < 0 {
-			ii = len(path)
+	found := false
+	for m, rts := range n.methods {
+		for j := range rts {
+			if rts[j] == r {
+				rts = append(rts[:j], rts[j+1:]...)
+				found = true
+				break
+			}
+		}
+		if len(rts) == 0 {
+			delete(n.methods, m)
+		} else {
+			n.methods[m] = rts
 		}
+	}
+	if !found {
+		return fmt.Errorf("route not found")
+	}
 
-		inode := n
+	// Prune the nodes without routes, from the leaf to the root
+	for i := len(nodes) - 1; i > 0; i-- {
+		nn := nodes[i]
+		if len(nn.methods) > 0 || len(nn.nodes) > 0 {
+			break
+		}
+		parent := nodes[i-1]
+		for j := range parent.nodes {
+			if parent.nodes[j] == nn {
+				parent.nodes = append(parent.nodes[:j], parent.nodes[j+1:]...)
+				break
+			}
+		}
+	}
 
-		// var parms []PathParam
-		// Find node which matches p
-		for j := range n.nodes {
-			match = n.nodes[j].seg.Match(path[:ii], parms)
-			if match {
-				// ctx.AddPathParamsWithParams(parms)
-				n = n.nodes[j]
+	t.depth, t.maxparams = t.node.measure()
+	return nil
+}
+
+// Fork returns a copy of the trie that can be changed to add or remove r
+// while t is in use. Only the nodes on the path of r are copied.
+func (t *trie) Fork(r *Route) PathRouter {
+	nt := &trie{node: *t.node.clone(), depth: t.depth, maxparams: t.maxparams}
+	if r == nil {
+		return nt
+	}
+
+	// Add or Remove will report the error
+	path, err := ParsePath(r.path)
+	if err != nil {
+		return nt
+	}
+
+	n := &nt.node
+	for _, s := range path {
+		inode := n
+		for j := range inode.nodes {
+			if inode.nodes[j].seg.Comparable() == s.Comparable() {
+				inode.nodes[j] = inode.nodes[j].clone()
+				n = inode.nodes[j]
 				break
 			}
 		}
+		if n == inode {
+			break
+		}
+	}
+
+	return nt
+}
 
-		// Not found
-		if inode == n {
-			return nil
+func (n *node) clone() *node {
+	nn := &node{seg: n.seg, nodes: append([]*node(nil), n.nodes...)}
+	if n.methods != nil {
+		nn.methods = make(map[string][]*Route, len(n.methods))
+		for m, rts := range n.methods {
+			nn.methods[m] = append([]*Route(nil), rts...)
 		}
+	}
+	return nn
+}
 
+// measure returns the depth and the max number of params of the paths below n
+func (n node) measure() (int, int) {
+	depth, maxparams := 0, 0
+	for _, nn := range n.nodes {
+		d, p := nn.measure()
+		d += 1
+		p += nn.seg.NumVars()
+		if depth < d {
+			depth = d
+		}
+		if maxparams < p {
+			maxparams = p
+		}
+	}
+	return depth, maxparams
+}
+
+// Get finds the node with routes matching path. The children of each node are
+// tried by priority (static, typed, any and catch all), backtracking into the
+// next sibling when the rest of the path is not found.
+func (t trie) Get(path string, ctx *Context) MatchResult {
+	ior := 0
+	if len(path) > 0 && path[0] == '/' {
+		ior += 1
+	}
+
+	// The params already on ctx, like the ones of a parent router, are kept
+	if ctx.pathParams == nil {
+		ctx.pathParams = make([]PathParam, 0, t.maxparams)
+	}
+
+	n := t.node.get(path, ior, ctx)
+	if n == nil {
+		return nil
+	}
+	return n
+}
+
+// get matches originalpath[ior:] on the children of n
+func (n *node) get(originalpath string, ior int, ctx *Context) *node {
+	path := originalpath[ior:]
+	i := strings.IndexByte(path, '/')
+	p := path
+	if i >= 0 {
+		p = path[:i]
+	}
+
+	mark := len(ctx.pathParams)
+	for _, nn := range n.nodes {
 		// Capture all the rest of path
-		if n.seg.CatchAll() {
-			// ctx.AddPathParamsWithParams(parms)
+		if nn.seg.CatchAll() {
+			if len(nn.methods) == 0 {
+				continue
+			}
 			ctx.AddPathParam("*", path)
-			// ctx.PathParams["*"] = path
-			ctx.RoutePath = originalpath[:ior-1]
-			return n
+			ctx.rest = path
+			ctx.RoutePath = strings.TrimSuffix(originalpath[:ior], "/")
+			return nn
+		}
+
+		if ctx.fold {
+			if !nn.seg.MatchFold(p, &ctx.pathParams) {
+				continue
+			}
+		} else if !nn.seg.Match(p, &ctx.pathParams) {
+			continue
 		}
 
-		// Time to send an answer
-		if i == -1 {
-			ctx.RoutePath = originalpath
-			// ctx.AddPathParamsWithParams(parms)
-			return n
+		if i < 0 {
+			// Time to send an answer
+			if len(nn.methods) > 0 {
+				ctx.RoutePath = originalpath
+				return nn
+			}
+		} else if found := nn.get(originalpath, ior+i+1, ctx); found != nil {
+			return found
 		}
-		path = path[i+1:]
-		ior += (i + 1)
+		ctx.pathParams = ctx.pathParams[:mark]
 	}
+
+	return nil
 }
 
 func (n node) GetAll(path string, ctx *Context) []*node {
@@ -417,3 +417,178 @@ func TestAddNilToTrie(t *testing.T) {
 func TestUrlEscape(t *testing.T) {
 	t.Log(url.PathEscape("<>"))
 }
+
+func TestTrieRemove(t *testing.T) {
+	tr := trie{}
+
+	paths := []string{"/a/b/c/d", "/a/b", "/a/{x}/{y}/{z}"}
+	routes := make([]*Route, len(paths))
+	for i, path := range paths {
+		routes[i], _ = NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path(path).Build()
+		if err := tr.Add(routes[i]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if tr.depth != 4 || tr.maxparams != 3 {
+		t.Fatalf("Wrong depth %v or maxparams %v", tr.depth, tr.maxparams)
+	}
+
+	if err := tr.Remove(routes[2]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tr.depth != 4 || tr.maxparams != 0 {
+		t.Fatalf("Wrong depth %v or maxparams %v", tr.depth, tr.maxparams)
+	}
+	if len(tr.nodes[0].nodes) != 1 {
+		t.Fatal("Empty nodes must be pruned")
+	}
+
+	if err := tr.Remove(routes[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tr.depth != 2 {
+		t.Fatalf("Wrong depth %v", tr.depth)
+	}
+	if len(tr.nodes[0].nodes[0].nodes) != 0 {
+		t.Fatal("Empty nodes must be pruned")
+	}
+
+	if err := tr.Remove(routes[0]); err == nil {
+		t.Fatal("Must have some error")
+	}
+
+	if err := tr.Remove(routes[1]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tr.nodes) != 0 || tr.depth != 0 {
+		t.Fatal("Trie must be empty")
+	}
+}
+
+func TestTrieFork(t *testing.T) {
+	ctx := &Context{}
+	ctx.Reset()
+
+	tr := &trie{}
+
+	r1, _ := NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path("/a/b").Build()
+	r2, _ := NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path("/a/c").Build()
+	if err := tr.Add(r1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	f1 := tr.Fork(r2)
+	if err := f1.Add(r2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tr.Get("/a/c", ctx) != nil {
+		t.Fatal("Original trie must not be changed")
+	}
+	if f1.Get("/a/c", ctx) == nil {
+		t.Fatal("Fork must have the new route")
+	}
+
+	f2 := f1.Fork(r1)
+	if err := f2.Remove(r1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f1.Get("/a/b", ctx) == nil || tr.Get("/a/b", ctx) == nil {
+		t.Fatal("Original tries must not be changed")
+	}
+	if f2.Get("/a/b", ctx) != nil {
+		t.Fatal("Fork must not have the removed route")
+	}
+}
+
+func TestTriePriority(t *testing.T) {
+	orders := [][]string{
+		{"/users/me", "/users/{id:uint}", "/users/{name}"},
+		{"/users/{name}", "/users/{id:uint}", "/users/me"},
+		{"/users/{id:uint}", "/users/{name}", "/users/me"},
+	}
+
+	for _, paths := range orders {
+		tr := trie{}
+		routes := make(map[string]*Route)
+		for _, path := range paths {
+			r, _ := NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path(path).Build()
+			if err := tr.Add(r); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			routes[path] = r
+		}
+
+		ctx := &Context{}
+		ctx.Reset()
+
+		testCases := []struct {
+			path  string
+			route string
+		}{
+			{"/users/me", "/users/me"},
+			{"/users/123", "/users/{id:uint}"},
+			{"/users/john", "/users/{name}"},
+		}
+		for _, tC := range testCases {
+			result := tr.Get(tC.path, ctx)
+			if result == nil {
+				t.Fatalf("Must find %v", tC.path)
+			}
+			if result.Methods()["GET"] != routes[tC.route] {
+				t.Fatalf("%v must match %v with order %v", tC.path, tC.route, paths)
+			}
+		}
+	}
+}
+
+func TestTrieBacktracking(t *testing.T) {
+	tr := trie{}
+
+	paths := []string{"/a/{x}/c", "/a/b/d", "/a/{x}/{y}/e", "/a/b/{z:uint}"}
+	routes := make(map[string]*Route)
+	for _, path := range paths {
+		r, _ := NewRoute().Handler(http.DefaultServeMux).Methods("GET").Path(path).Build()
+		if err := tr.Add(r); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		routes[path] = r
+	}
+
+	ctx := &Context{}
+	ctx.Reset()
+
+	testCases := []struct {
+		path   string
+		route  string
+		params map[string]string
+	}{
+		{"/a/b/d", "/a/b/d", nil},
+		{"/a/b/c", "/a/{x}/c", map[string]string{"x": "b"}},
+		{"/a/b/7", "/a/b/{z:uint}", map[string]string{"z": "7"}},
+		{"/a/b/f/e", "/a/{x}/{y}/e", map[string]string{"x": "b", "y": "f"}},
+	}
+	for _, tC := range testCases {
+		ctx.Reset()
+		result := tr.Get(tC.path, ctx)
+		if result == nil {
+			t.Fatalf("Must find %v", tC.path)
+		}
+		if result.Methods()["GET"] != routes[tC.route] {
+			t.Fatalf("%v must match %v", tC.path, tC.route)
+		}
+		if len(ctx.pathParams) != len(tC.params) {
+			t.Fatalf("Wrong params for %v: %v", tC.path, ctx.pathParams)
+		}
+		for k, v := range tC.params {
+			if ctx.PathParam(k) != v {
+				t.Fatalf("Wrong param %v for %v: %v", k, tC.path, ctx.PathParam(k))
+			}
+		}
+	}
+
+	// Nodes without routes are not an answer
+	if tr.Get("/a/b", ctx) != nil {
+		t.Fatal("/a/b must not be found")
+	}
+}
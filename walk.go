@@ -0,0 +1,163 @@
+package smux
+
+import "fmt"
+
+// WalkFunc is called for each route of the table in use. host is the host
+// pattern of the route, empty for all hosts, and segments are the segments of
+// its path, like "users" and "{id:uint}".
+type WalkFunc func(route *Route, host string, segments []string) error
+
+// Walk calls fn for each route of the table in use, in the order they are
+// tried: host by host, ending with the routes for all hosts, and on each
+// host by path, with the children of each segment by priority. The routes
+// sharing the path are given in the order of their methods. Walk stops on
+// the first error of fn and returns it.
+func (router *Router) Walk(fn WalkFunc) error {
+	h := router.hostRouter()
+	if h == nil {
+		return fmt.Errorf("router not compiled")
+	}
+	return h.Walk(fn)
+}
+
+func (h HostRouter) Walk(fn WalkFunc) error {
+	walk := func(host string, t PathRouter) error {
+		return t.Walk(func(segments []string, m MatchResult) error {
+			seen := make(map[*Route]struct{})
+			for _, method := range m.AllowedMethods() {
+				for _, rt := range m.Routes(method) {
+					if _, found := seen[rt]; found {
+						continue
+					}
+					seen[rt] = struct{}{}
+					if err := fn(rt, host, routeSegments(rt, segments)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+
+	for _, he := range h.hosts {
+		if err := walk(he.host, he.t); err != nil {
+			return err
+		}
+	}
+	return walk("", h.allhost)
+}
+
+// routeSegments returns the segments of the path of rt, which name the params
+// as rt does. The segments of the node are named after the first route added.
+func routeSegments(rt *Route, segments []string) []string {
+	segs, err := ParsePath(rt.path)
+	if err != nil || len(segs) != len(segments) {
+		return segments
+	}
+	own := make([]string, len(segs))
+	for i, seg := range segs {
+		own[i] = segmentPattern(seg)
+	}
+	return own
+}
+
+// Walk calls fn with the segments of each node with routes, by priority
+func (t trie) Walk(fn func(segments []string, m MatchResult) error) error {
+	return t.node.walk(make([]string, 0, t.depth), fn)
+}
+
+func (n *node) walk(segments []string, fn func([]string, MatchResult) error) error {
+	for _, nn := range n.nodes {
+		segs := append(segments, segmentPattern(nn.seg))
+		if len(nn.methods) > 0 {
+			if err := fn(append([]string(nil), segs...), nn); err != nil {
+				return err
+			}
+		}
+		if err := nn.walk(segs, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segmentPattern returns seg as written on paths
+func segmentPattern(seg segment) string {
+	if seg.CatchAll() {
+		return "{*}"
+	}
+	return seg.String()
+}
+
+// HostRouterDump describes a compiled table, for documentation and checks.
+// It can be encoded to JSON.
+type HostRouterDump struct {
+	// Hosts are in the order they are tried, ending with all hosts
+	Hosts []HostDump `json:"hosts"`
+}
+
+type HostDump struct {
+	// Host is the host pattern, empty for all hosts
+	Host  string     `json:"host"`
+	Nodes []NodeDump `json:"nodes"`
+}
+
+type NodeDump struct {
+	Segment string `json:"segment"`
+	// Kind is static, typed, any or catchall
+	Kind string `json:"kind"`
+	// Methods has the names of the routes of each method, in the order they
+	// are tried
+	Methods map[string][]string `json:"methods,omitempty"`
+	Nodes   []NodeDump          `json:"nodes,omitempty"`
+}
+
+// Dump describes the table in use, nil when the router is not compiled
+func (router *Router) Dump() *HostRouterDump {
+	h := router.hostRouter()
+	if h == nil {
+		return nil
+	}
+	d := h.Dump()
+	return &d
+}
+
+func (h HostRouter) Dump() HostRouterDump {
+	d := HostRouterDump{Hosts: make([]HostDump, 0, len(h.hosts)+1)}
+	for _, he := range h.hosts {
+		d.Hosts = append(d.Hosts, HostDump{Host: he.host, Nodes: he.t.Dump()})
+	}
+	d.Hosts = append(d.Hosts, HostDump{Nodes: h.allhost.Dump()})
+	return d
+}
+
+// Dump describes the nodes of the trie, by priority
+func (t trie) Dump() []NodeDump {
+	return t.node.dump()
+}
+
+var segmentKinds = []string{
+	prioritystatic:   "static",
+	prioritytyped:    "typed",
+	priorityany:      "any",
+	prioritycatchall: "catchall",
+}
+
+func (n node) dump() []NodeDump {
+	nodes := make([]NodeDump, 0, len(n.nodes))
+	for _, nn := range n.nodes {
+		d := NodeDump{Segment: segmentPattern(nn.seg), Kind: segmentKinds[nn.seg.Priority()], Nodes: nn.dump()}
+		if len(nn.methods) > 0 {
+			d.Methods = make(map[string][]string, len(nn.methods))
+			for m, rts := range nn.methods {
+				names := make([]string, len(rts))
+				for i, rt := range rts {
+					names[i] = rt.name
+				}
+				d.Methods[m] = names
+			}
+		}
+		nodes = append(nodes, d)
+	}
+	return nodes
+}
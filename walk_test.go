@@ -0,0 +1,101 @@
+package smux
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newWalkRouter(t *testing.T) *Router {
+	router := NewRouter()
+	router.SetHostnames([]string{"api.local.com"})
+
+	builders := []*RouteBuilder{
+		NewRoute().Name("user").Path("/users/{id:uint}").Methods("GET", "PUT"),
+		NewRoute().Name("users").Path("/users").Methods("GET"),
+		NewRoute().Name("user-v2").Path("/users/{id:uint}").Methods("GET").Headers("X-Api-Version", "2"),
+		NewRoute().Name("me").Path("/users/me").Methods("GET"),
+		NewRoute().Name("static").Path("/static/{*}").Methods("GET"),
+		NewRoute().Name("api").Host("api.local.com").Path("/status").Methods("GET"),
+	}
+	addRoutes(t, router, builders...)
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Error compiling: %v", err)
+	}
+	return router
+}
+
+func TestWalk(t *testing.T) {
+	router := NewRouter()
+	if err := router.Walk(func(*Route, string, []string) error { return nil }); err == nil {
+		t.Fatalf("Walk must fail before Compile")
+	}
+
+	router = newWalkRouter(t)
+	var walked []string
+	err := router.Walk(func(route *Route, host string, segments []string) error {
+		walked = append(walked, fmt.Sprintf("%v %v /%v", route.Name(), host, strings.Join(segments, "/")))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"api api.local.com /status",
+		"users  /users",
+		"me  /users/me",
+		"user-v2  /users/{id:uint}",
+		"user  /users/{id:uint}",
+		"static  /static/{*}",
+	}
+	if !reflect.DeepEqual(walked, expected) {
+		t.Fatalf("Expected %v but was %v", expected, walked)
+	}
+
+	stop := fmt.Errorf("stop")
+	count := 0
+	err = router.Walk(func(route *Route, host string, segments []string) error {
+		count += 1
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Fatalf("Walk must stop on the first error, was %v after %v routes", err, count)
+	}
+}
+
+func TestDump(t *testing.T) {
+	if NewRouter().Dump() != nil {
+		t.Fatalf("Dump must be nil before Compile")
+	}
+
+	d := newWalkRouter(t).Dump()
+	if len(d.Hosts) != 2 || d.Hosts[0].Host != "api.local.com" || d.Hosts[1].Host != "" {
+		t.Fatalf("Unexpected hosts %+v", d.Hosts)
+	}
+
+	users := d.Hosts[1].Nodes[0]
+	if users.Segment != "users" || users.Kind != "static" || !reflect.DeepEqual(users.Methods["GET"], []string{"users"}) {
+		t.Fatalf("Unexpected node %+v", users)
+	}
+	id := users.Nodes[1]
+	if id.Segment != "{id:uint}" || id.Kind != "typed" {
+		t.Fatalf("Unexpected node %+v", id)
+	}
+	if !reflect.DeepEqual(id.Methods["GET"], []string{"user-v2", "user"}) || !reflect.DeepEqual(id.Methods["PUT"], []string{"user"}) {
+		t.Fatalf("Unexpected methods %v", id.Methods)
+	}
+	if static := d.Hosts[1].Nodes[1].Nodes[0]; static.Segment != "{*}" || static.Kind != "catchall" {
+		t.Fatalf("Unexpected node %+v", static)
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(b), `{"segment":"status","kind":"static","methods":{"GET":["api"]}}`) {
+		t.Fatalf("Unexpected JSON %s", b)
+	}
+}